@@ -0,0 +1,130 @@
+package log
+
+import "context"
+
+type loggerContextKey struct{}
+
+type fieldsContextKey struct{}
+
+type traceContextKey struct{}
+
+// TraceContext identifies the trace and span an entry was logged in
+type TraceContext struct {
+	// TraceID is the 32 hex digit trace id
+	TraceID string
+	// SpanID is the 16 hex digit span id
+	SpanID  string
+	Sampled bool
+}
+
+// IntoContext returns a copy of ctx carrying the logger, package level logging
+// functions called with the returned context use it instead of the package
+// level logger
+func IntoContext(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the package level logger
+// if there is none
+func FromContext(ctx context.Context) Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerContextKey{}).(Logger); ok {
+			return logger
+		}
+	}
+
+	return globalLogger
+}
+
+// fromContext is FromContext for the package level functions, zap loggers are
+// replaced by their helper so the caller of the function is reported
+func fromContext(ctx context.Context) Logger {
+	logger := FromContext(ctx)
+	if l, ok := logger.(*ZapLogger); ok && l.helper != nil {
+		return l.helper
+	}
+
+	return logger
+}
+
+// ContextWithFields returns a copy of ctx carrying the fields in addition to
+// the ones already carried by ctx. A field replaces an earlier one with the
+// same key. Loggers add the fields to every entry logged with the context,
+// unless the entry has a call site field with the same key. Fields added by
+// With, Withw and WithStaticFields are encoded ahead of the entry and are not
+// compared, a context field with the same key as one of them is written as
+// a second key.
+func ContextWithFields(ctx context.Context, fields ...Field) context.Context {
+	parent := FieldsFromContext(ctx)
+
+	merged := make([]Field, 0, len(parent)+len(fields))
+	for _, field := range parent {
+		if !hasField(fields, field.Key) {
+			merged = append(merged, field)
+		}
+	}
+	merged = append(merged, fields...)
+
+	return context.WithValue(ctx, fieldsContextKey{}, merged)
+}
+
+// ContextWithKeyAndValues is like ContextWithFields with loosely typed
+// key-value pairs
+func ContextWithKeyAndValues(ctx context.Context, keyAndValues ...interface{}) context.Context {
+	return ContextWithFields(ctx, sweetenFields(keyAndValues)...)
+}
+
+// FieldsFromContext returns the fields carried by ctx
+func FieldsFromContext(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+
+	fields, _ := ctx.Value(fieldsContextKey{}).([]Field)
+	return fields
+}
+
+// ContextWithTrace returns a copy of ctx carrying the trace, loggers add it to
+// every entry logged with the context
+func ContextWithTrace(ctx context.Context, trace TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, trace)
+}
+
+// TraceFromContext returns the trace carried by ctx, it is the default of
+// WithTraceExtractor
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	if ctx == nil {
+		return TraceContext{}, false
+	}
+
+	trace, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return trace, ok
+}
+
+// mergeFields appends the extra fields whose keys are not present in fields,
+// so fields take precedence on key collisions
+func mergeFields(fields []Field, extra []Field) []Field {
+	if len(extra) == 0 {
+		return fields
+	}
+
+	merged := make([]Field, len(fields), len(fields)+len(extra))
+	copy(merged, fields)
+	for _, field := range extra {
+		if field.Type == SkipType || !hasField(fields, field.Key) {
+			merged = append(merged, field)
+		}
+	}
+
+	return merged
+}
+
+func hasField(fields []Field, key string) bool {
+	for _, field := range fields {
+		if field.Type != SkipType && field.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
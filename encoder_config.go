@@ -0,0 +1,181 @@
+package log
+
+import (
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// TimeFormat is how encoders render the time of entries and time fields
+type TimeFormat string
+
+const (
+	TimeISO8601      TimeFormat = "iso8601"
+	TimeRFC3339      TimeFormat = "rfc3339"
+	TimeRFC3339Nano  TimeFormat = "rfc3339nano"
+	TimeEpochSeconds TimeFormat = "epoch"
+	TimeEpochMillis  TimeFormat = "epoch_millis"
+	TimeEpochNanos   TimeFormat = "epoch_nanos"
+)
+
+// LevelCase is the casing of levels, ColorConsole colors either of them
+type LevelCase string
+
+const (
+	LevelCapital   LevelCase = "capital"
+	LevelLowercase LevelCase = "lowercase"
+)
+
+// DurationFormat is how encoders render durations
+type DurationFormat string
+
+const (
+	DurationString  DurationFormat = "string"
+	DurationSeconds DurationFormat = "seconds"
+	DurationMillis  DurationFormat = "millis"
+	DurationNanos   DurationFormat = "nanos"
+)
+
+// EncoderConfig sets the keys and formats of encoders. Empty keys leave the
+// element out, other zero values take the first format of their type.
+type EncoderConfig struct {
+	TimeKey       string
+	LevelKey      string
+	MessageKey    string
+	CallerKey     string
+	StacktraceKey string
+	NameKey       string
+
+	TimeFormat TimeFormat
+	// TimeLayout is a time.Format layout overriding TimeFormat
+	TimeLayout string
+	// UTC converts times to UTC before formatting them
+	UTC bool
+
+	LevelCase      LevelCase
+	DurationFormat DurationFormat
+	// FullCaller writes the full path of the caller instead of package/file
+	FullCaller bool
+
+	// Namespace nests the fields of entries under a key, so they can't clash
+	// with the keys above
+	Namespace string
+
+	// ProjectID qualifies trace ids in GCP output as
+	// projects/<ProjectID>/traces/<TraceID>
+	ProjectID string
+}
+
+// DevelopmentEncoderConfig is the default of JSON and Console, with single
+// letter keys and ISO8601 local times
+func DevelopmentEncoderConfig() EncoderConfig {
+	return EncoderConfig{
+		TimeKey:        "T",
+		LevelKey:       "L",
+		MessageKey:     "M",
+		CallerKey:      "C",
+		StacktraceKey:  "S",
+		NameKey:        "N",
+		TimeFormat:     TimeISO8601,
+		LevelCase:      LevelCapital,
+		DurationFormat: DurationString,
+	}
+}
+
+// ProductionEncoderConfig uses descriptive keys, RFC3339 UTC times with
+// nanoseconds, lowercase levels and durations in seconds
+func ProductionEncoderConfig() EncoderConfig {
+	return EncoderConfig{
+		TimeKey:        "ts",
+		LevelKey:       "level",
+		MessageKey:     "msg",
+		CallerKey:      "caller",
+		StacktraceKey:  "stacktrace",
+		NameKey:        "logger",
+		TimeFormat:     TimeRFC3339Nano,
+		UTC:            true,
+		LevelCase:      LevelLowercase,
+		DurationFormat: DurationSeconds,
+	}
+}
+
+// logfmtEncoderConfig is the default of Logfmt
+func logfmtEncoderConfig() EncoderConfig {
+	config := ProductionEncoderConfig()
+	config.UTC = false
+	config.DurationFormat = DurationString
+
+	return config
+}
+
+// zapEncoderConfig converts the config, color selects the colored level
+// encoders of ColorConsole
+func (c EncoderConfig) zapEncoderConfig(color bool) zapcore.EncoderConfig {
+	config := zapcore.EncoderConfig{
+		TimeKey:        c.TimeKey,
+		LevelKey:       c.LevelKey,
+		NameKey:        c.NameKey,
+		CallerKey:      c.CallerKey,
+		FunctionKey:    zapcore.OmitKey,
+		MessageKey:     c.MessageKey,
+		StacktraceKey:  c.StacktraceKey,
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    capitalLevelEncoder,
+		EncodeTime:     c.timeEncoder(),
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+		EncodeName:     zapcore.FullNameEncoder,
+	}
+
+	switch {
+	case c.LevelCase == LevelLowercase && color:
+		config.EncodeLevel = lowercaseColorLevelEncoder
+	case c.LevelCase == LevelLowercase:
+		config.EncodeLevel = lowercaseLevelEncoder
+	case color:
+		config.EncodeLevel = capitalColorLevelEncoder
+	}
+
+	switch c.DurationFormat {
+	case DurationSeconds:
+		config.EncodeDuration = zapcore.SecondsDurationEncoder
+	case DurationMillis:
+		config.EncodeDuration = zapcore.MillisDurationEncoder
+	case DurationNanos:
+		config.EncodeDuration = zapcore.NanosDurationEncoder
+	}
+
+	if c.FullCaller {
+		config.EncodeCaller = zapcore.FullCallerEncoder
+	}
+
+	return config
+}
+
+func (c EncoderConfig) timeEncoder() zapcore.TimeEncoder {
+	var encoder zapcore.TimeEncoder
+	switch {
+	case c.TimeLayout != "":
+		encoder = zapcore.TimeEncoderOfLayout(c.TimeLayout)
+	case c.TimeFormat == TimeRFC3339:
+		encoder = zapcore.RFC3339TimeEncoder
+	case c.TimeFormat == TimeRFC3339Nano:
+		encoder = zapcore.RFC3339NanoTimeEncoder
+	case c.TimeFormat == TimeEpochSeconds:
+		encoder = zapcore.EpochTimeEncoder
+	case c.TimeFormat == TimeEpochMillis:
+		encoder = zapcore.EpochMillisTimeEncoder
+	case c.TimeFormat == TimeEpochNanos:
+		encoder = zapcore.EpochNanosTimeEncoder
+	default:
+		encoder = zapcore.ISO8601TimeEncoder
+	}
+
+	if !c.UTC {
+		return encoder
+	}
+
+	return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		encoder(t.UTC(), enc)
+	}
+}
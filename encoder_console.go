@@ -0,0 +1,84 @@
+package log
+
+import (
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// zapDict is the zapcore.ObjectMarshaler of a Dict field
+type zapDict []zapcore.Field
+
+func (d zapDict) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, field := range d {
+		field.AddTo(enc)
+	}
+
+	return nil
+}
+
+// flatten appends the fields of the dict to fields with keys prefixed by key
+func (d zapDict) flatten(key string, fields []zapcore.Field) []zapcore.Field {
+	for _, field := range d {
+		if dict, ok := field.Interface.(zapDict); ok && field.Type == zapcore.ObjectMarshalerType {
+			fields = dict.flatten(key+"."+field.Key, fields)
+			continue
+		}
+
+		if field.Type != zapcore.SkipType {
+			field.Key = key + "." + field.Key
+		}
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// consoleEncoder is the zap console encoder rendering Dict fields as dotted keys
+type consoleEncoder struct {
+	zapcore.Encoder
+}
+
+func (e consoleEncoder) Clone() zapcore.Encoder {
+	return consoleEncoder{e.Encoder.Clone()}
+}
+
+// AddObject is called with the fields added by With
+func (e consoleEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	dict, ok := marshaler.(zapDict)
+	if !ok {
+		return e.Encoder.AddObject(key, marshaler)
+	}
+
+	for _, field := range dict.flatten(key, nil) {
+		field.AddTo(e.Encoder)
+	}
+
+	return nil
+}
+
+func (e consoleEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	return e.Encoder.EncodeEntry(entry, flattenDicts(fields))
+}
+
+// flattenDicts replaces Dict fields by their fields with dotted keys
+func flattenDicts(fields []zapcore.Field) []zapcore.Field {
+	for index, field := range fields {
+		if _, ok := field.Interface.(zapDict); !ok || field.Type != zapcore.ObjectMarshalerType {
+			continue
+		}
+
+		flattened := make([]zapcore.Field, index, len(fields))
+		copy(flattened, fields[:index])
+		for _, field := range fields[index:] {
+			if dict, ok := field.Interface.(zapDict); ok && field.Type == zapcore.ObjectMarshalerType {
+				flattened = dict.flatten(field.Key, flattened)
+				continue
+			}
+			flattened = append(flattened, field)
+		}
+
+		return flattened
+	}
+
+	return fields
+}
@@ -0,0 +1,88 @@
+package log
+
+import (
+	"bytes"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// documentEncoder writes JSON documents for log backends with their own
+// schema, like ECS and Cloud Logging. The entry and the fields picked by split
+// are written at the top level by the header encoder, the remaining fields
+// follow under the namespace of the embedded encoder, which is left out when
+// there are no fields.
+type documentEncoder struct {
+	zapcore.Encoder
+	header zapcore.Encoder
+	// empty is the fields document without any field
+	empty string
+	split func(entry zapcore.Entry, fields []zapcore.Field) (top, rest []zapcore.Field)
+}
+
+// newDocumentEncoder creates a document encoder, only the namespace and the
+// formats of time and duration fields are taken from config
+func newDocumentEncoder(config EncoderConfig, header zapcore.EncoderConfig,
+	split func(zapcore.Entry, []zapcore.Field) ([]zapcore.Field, []zapcore.Field)) documentEncoder {
+	fieldsConfig := config.zapEncoderConfig(false)
+	fieldsConfig.TimeKey = zapcore.OmitKey
+	fieldsConfig.LevelKey = zapcore.OmitKey
+	fieldsConfig.NameKey = zapcore.OmitKey
+	fieldsConfig.CallerKey = zapcore.OmitKey
+	fieldsConfig.MessageKey = zapcore.OmitKey
+	fieldsConfig.StacktraceKey = zapcore.OmitKey
+
+	fields := zapcore.NewJSONEncoder(fieldsConfig)
+	if config.Namespace != "" {
+		fields.OpenNamespace(config.Namespace)
+	}
+
+	header.LineEnding = zapcore.DefaultLineEnding
+
+	empty := "{}" + zapcore.DefaultLineEnding
+	if document, err := fields.Clone().EncodeEntry(zapcore.Entry{}, nil); err == nil {
+		empty = document.String()
+		document.Free()
+	}
+
+	return documentEncoder{Encoder: fields, header: zapcore.NewJSONEncoder(header), empty: empty, split: split}
+}
+
+func (e documentEncoder) Clone() zapcore.Encoder {
+	return documentEncoder{Encoder: e.Encoder.Clone(), header: e.header, empty: e.empty, split: e.split}
+}
+
+func (e documentEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	top, rest := e.split(entry, fields)
+
+	head, err := e.header.EncodeEntry(entry, top)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := e.Encoder.EncodeEntry(zapcore.Entry{}, rest)
+	if err != nil {
+		head.Free()
+		return nil, err
+	}
+	defer body.Free()
+
+	// join {"time":...}\n and {"namespace":{...}}\n into one document
+	document := bytes.TrimSuffix(head.Bytes(), []byte("}"+zapcore.DefaultLineEnding))
+	if fieldsDocument := body.Bytes(); string(fieldsDocument) != e.empty {
+		document = append(append(document, ','), fieldsDocument[1:]...)
+	} else {
+		document = append(document, "}"+zapcore.DefaultLineEnding...)
+	}
+
+	head.Reset()
+	head.Write(document)
+
+	return head, nil
+}
+
+// withoutField returns fields without the field at index, the fields are
+// pooled by the logger so they are copied
+func withoutField(fields []zapcore.Field, index int) []zapcore.Field {
+	return append(fields[:index:index], fields[index+1:]...)
+}
@@ -0,0 +1,186 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ecsVersion is the version of the Elastic Common Schema the ECS encoder follows
+const ecsVersion = "8.11.0"
+
+// ECSEncoderConfig is the default of ECS, it puts the fields of entries under
+// the fields namespace
+func ECSEncoderConfig() EncoderConfig {
+	config := ProductionEncoderConfig()
+	config.Namespace = "fields"
+
+	return config
+}
+
+// newECSEncoder creates the encoder of ECS documents. The first Err field of
+// an entry becomes the ECS error, the fields of its LogFielder errors join the
+// other fields, and the trace becomes trace.id and span.id.
+func newECSEncoder(config EncoderConfig) documentEncoder {
+	header := zapcore.EncoderConfig{
+		TimeKey:       "@timestamp",
+		LevelKey:      "log.level",
+		NameKey:       "log.logger",
+		MessageKey:    "message",
+		StacktraceKey: "error.stack_trace",
+		EncodeLevel:   lowercaseLevelEncoder,
+		EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			zapcore.RFC3339NanoTimeEncoder(t.UTC(), enc)
+		},
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeName:     zapcore.FullNameEncoder,
+	}
+
+	split := func(entry zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, []zapcore.Field) {
+		top := make([]zapcore.Field, 0, 5)
+		top = append(top, zap.String("ecs.version", ecsVersion))
+		if entry.Caller.Defined {
+			top = append(top, zap.Object("log.origin", ecsOrigin{caller: entry.Caller, full: config.FullCaller}))
+		}
+
+		var errorFields []zapcore.Field
+		errorFound := false
+		for index := 0; index < len(fields); index++ {
+			field := fields[index]
+			if field.Type != zapcore.InlineMarshalerType {
+				continue
+			}
+
+			switch marshaler := field.Interface.(type) {
+			case zapError:
+				if errorFound || marshaler.key != "error" {
+					continue
+				}
+				errorFound = true
+				ecsErr := newECSError(marshaler.err)
+				top = append(top, zap.Object("error", ecsErr))
+				if len(ecsErr.detail.fields) > 0 {
+					errorFields = marshaler.logger.parseFields(ecsErr.detail.fields)
+				}
+			case zapTrace:
+				top = append(top, zap.Object("trace", ecsID(marshaler.TraceID)))
+				if marshaler.SpanID != "" {
+					top = append(top, zap.Object("span", ecsID(marshaler.SpanID)))
+				}
+			default:
+				continue
+			}
+
+			fields = withoutField(fields, index)
+			index--
+		}
+
+		if len(errorFields) > 0 {
+			// ahead of the entry fields, which may open a namespace
+			fields = append(errorFields, fields...)
+		}
+
+		return top, fields
+	}
+
+	return newDocumentEncoder(config, header, split)
+}
+
+// ecsID is the object of trace.id and span.id
+type ecsID string
+
+func (id ecsID) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("id", string(id))
+	return nil
+}
+
+// ecsOrigin is log.origin with the file and function of the caller
+type ecsOrigin struct {
+	caller zapcore.EntryCaller
+	full   bool
+}
+
+func (o ecsOrigin) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	name := o.caller.File
+	if !o.full {
+		// package/file.go like the short caller
+		trimmed := o.caller.TrimmedPath()
+		name = trimmed[:strings.LastIndexByte(trimmed, ':')]
+	}
+
+	err := enc.AddObject("file", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddString("name", name)
+		enc.AddInt("line", o.caller.Line)
+		return nil
+	}))
+
+	if o.caller.Function != "" {
+		enc.AddString("function", o.caller.Function)
+	}
+
+	return err
+}
+
+// ecsError is the ECS error of an Err field, the wrapped and joined errors
+// are written under causes
+type ecsError struct {
+	err    error
+	detail errorDetail
+}
+
+func newECSError(err error) (e ecsError) {
+	e.err = err
+
+	// errors with a nil receiver may panic while the chain is walked
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			e.detail = errorDetail{errorType: fmt.Sprintf("%T", err)}
+		}
+	}()
+	e.detail = newErrorDetail(err)
+
+	return e
+}
+
+func (e ecsError) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+	// errors with a nil receiver may panic in Error
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			enc.AddString("message", fmt.Sprintf("PANIC=%v", recovered))
+		}
+	}()
+
+	enc.AddString("message", e.err.Error())
+	enc.AddString("type", e.detail.errorType)
+	if e.detail.stack != "" {
+		enc.AddString("stack_trace", e.detail.stack)
+	}
+	if len(e.detail.causes) > 0 {
+		err = enc.AddArray("causes", ecsErrorCauses(e.detail.causes))
+	}
+
+	return err
+}
+
+// ecsErrorCauses are the causes of an ECS error, with the message and type
+// keys of the error itself
+type ecsErrorCauses []errorCause
+
+func (c ecsErrorCauses) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, cause := range c {
+		cause := cause
+		err := enc.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("message", cause.message)
+			enc.AddString("type", cause.errorType)
+			return nil
+		}))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package log
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	gcpSourceLocationKey = "logging.googleapis.com/sourceLocation"
+	gcpTraceKey          = "logging.googleapis.com/trace"
+	gcpSpanIDKey         = "logging.googleapis.com/spanId"
+	gcpTraceSampledKey   = "logging.googleapis.com/trace_sampled"
+)
+
+// GCPEncoderConfig is the default of GCP, it puts the fields of entries under
+// the fields namespace so they can't replace message, severity or timestamp
+func GCPEncoderConfig() EncoderConfig {
+	config := ProductionEncoderConfig()
+	config.Namespace = "fields"
+
+	return config
+}
+
+// newGCPEncoder creates the encoder of Cloud Logging structured JSON. The
+// trace of an entry is qualified by the project of config, or of the
+// GOOGLE_CLOUD_PROJECT environment variable, so Cloud Logging links it to
+// Cloud Trace. Without a project the bare trace id is written, which Cloud
+// Logging keeps but doesn't link.
+func newGCPEncoder(config EncoderConfig) documentEncoder {
+	project := config.ProjectID
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+
+	header := zapcore.EncoderConfig{
+		TimeKey:       "timestamp",
+		LevelKey:      "severity",
+		NameKey:       "logger",
+		MessageKey:    "message",
+		StacktraceKey: "stack_trace",
+		EncodeLevel:   gcpSeverityEncoder,
+		EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			zapcore.RFC3339NanoTimeEncoder(t.UTC(), enc)
+		},
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeName:     zapcore.FullNameEncoder,
+	}
+
+	split := func(entry zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, []zapcore.Field) {
+		top := make([]zapcore.Field, 0, 4)
+		if entry.Caller.Defined {
+			top = append(top, zap.Object(gcpSourceLocationKey, gcpSourceLocation(entry.Caller)))
+		}
+
+		for index, field := range fields {
+			trace, ok := field.Interface.(zapTrace)
+			if !ok || field.Type != zapcore.InlineMarshalerType {
+				continue
+			}
+
+			traceID := trace.TraceID
+			if project != "" {
+				traceID = "projects/" + project + "/traces/" + traceID
+			}
+
+			top = append(top, zap.String(gcpTraceKey, traceID))
+			if trace.SpanID != "" {
+				top = append(top, zap.String(gcpSpanIDKey, trace.SpanID))
+			}
+			top = append(top, zap.Bool(gcpTraceSampledKey, trace.Sampled))
+
+			return top, withoutField(fields, index)
+		}
+
+		return top, fields
+	}
+
+	return newDocumentEncoder(config, header, split)
+}
+
+// gcpSeverityEncoder writes the Cloud Logging severity of a level, trace is
+// reported as DEBUG
+func gcpSeverityEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch {
+	case level <= zapcore.DebugLevel:
+		enc.AppendString("DEBUG")
+	case level == zapcore.InfoLevel:
+		enc.AppendString("INFO")
+	case level == zapcore.WarnLevel:
+		enc.AppendString("WARNING")
+	case level == zapcore.ErrorLevel:
+		enc.AppendString("ERROR")
+	case level == zapcore.DPanicLevel:
+		enc.AppendString("CRITICAL")
+	case level == zapcore.PanicLevel:
+		enc.AppendString("ALERT")
+	default:
+		enc.AppendString("EMERGENCY")
+	}
+}
+
+// gcpSourceLocation is the LogEntrySourceLocation of the caller, the line is
+// an int64 which Cloud Logging expects as a string
+type gcpSourceLocation zapcore.EntryCaller
+
+func (l gcpSourceLocation) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("file", l.File)
+	enc.AddString("line", strconv.Itoa(l.Line))
+	if l.Function != "" {
+		enc.AddString("function", l.Function)
+	}
+
+	return nil
+}
@@ -0,0 +1,402 @@
+package log
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtBufferPool = buffer.NewPool()
+
+// logfmtEncoder renders entries as logfmt key=value pairs. Nested objects and
+// arrays are flattened to dotted keys like request.id=1 and tags.0=a.
+type logfmtEncoder struct {
+	*zapcore.EncoderConfig
+	buf *buffer.Buffer
+	// prefix is prepended to keys inside objects and namespaces
+	prefix string
+}
+
+func newLogfmtEncoder(config zapcore.EncoderConfig) *logfmtEncoder {
+	return &logfmtEncoder{EncoderConfig: &config, buf: logfmtBufferPool.Get()}
+}
+
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	return e.clone()
+}
+
+func (e *logfmtEncoder) clone() *logfmtEncoder {
+	clone := &logfmtEncoder{EncoderConfig: e.EncoderConfig, buf: logfmtBufferPool.Get(), prefix: e.prefix}
+	clone.buf.Write(e.buf.Bytes())
+	return clone
+}
+
+func (e *logfmtEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := &logfmtEncoder{EncoderConfig: e.EncoderConfig, buf: logfmtBufferPool.Get()}
+
+	if e.TimeKey != "" && e.EncodeTime != nil {
+		final.addKey(e.TimeKey)
+		e.EncodeTime(entry.Time, logfmtValueEncoder{final})
+	}
+	if e.LevelKey != "" && e.EncodeLevel != nil {
+		final.addKey(e.LevelKey)
+		e.EncodeLevel(entry.Level, logfmtValueEncoder{final})
+	}
+	if e.NameKey != "" && entry.LoggerName != "" {
+		final.addKey(e.NameKey)
+		if e.EncodeName != nil {
+			e.EncodeName(entry.LoggerName, logfmtValueEncoder{final})
+		} else {
+			final.appendString(entry.LoggerName)
+		}
+	}
+	if e.CallerKey != "" && entry.Caller.Defined && e.EncodeCaller != nil {
+		final.addKey(e.CallerKey)
+		e.EncodeCaller(entry.Caller, logfmtValueEncoder{final})
+	}
+	if e.FunctionKey != "" && entry.Caller.Defined {
+		final.AddString(e.FunctionKey, entry.Caller.Function)
+	}
+	if e.MessageKey != "" {
+		final.AddString(e.MessageKey, entry.Message)
+	}
+
+	if e.buf.Len() > 0 {
+		if final.buf.Len() > 0 {
+			final.buf.AppendByte(' ')
+		}
+		final.buf.Write(e.buf.Bytes())
+	}
+
+	final.prefix = e.prefix
+	for _, field := range fields {
+		field.AddTo(final)
+	}
+	final.prefix = ""
+
+	if e.StacktraceKey != "" && entry.Stack != "" {
+		final.AddString(e.StacktraceKey, entry.Stack)
+	}
+
+	if e.LineEnding != "" {
+		final.buf.AppendString(e.LineEnding)
+	} else {
+		final.buf.AppendString(zapcore.DefaultLineEnding)
+	}
+
+	return final.buf, nil
+}
+
+// addKey starts a pair, keys are sanitized so they can't break the line up
+func (e *logfmtEncoder) addKey(key string) {
+	if e.buf.Len() > 0 {
+		e.buf.AppendByte(' ')
+	}
+
+	key = e.prefix + key
+	if key == "" {
+		key = "_"
+	}
+
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			e.buf.AppendByte('_')
+		} else {
+			e.buf.AppendString(string(r))
+		}
+	}
+	e.buf.AppendByte('=')
+}
+
+// appendString appends s, quoted if it is empty or contains spaces, equal
+// signs, quotes or control characters
+func (e *logfmtEncoder) appendString(s string) {
+	if needsLogfmtQuote(s) {
+		e.buf.AppendString(strconv.Quote(s))
+		return
+	}
+
+	e.buf.AppendString(s)
+}
+
+func needsLogfmtQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nested calls fn with the prefix of key, so the pairs it adds become
+// key.<name>
+func (e *logfmtEncoder) nested(key string, fn func() error) error {
+	prefix := e.prefix
+	e.prefix = prefix + key + "."
+	err := fn()
+	e.prefix = prefix
+
+	return err
+}
+
+func (e *logfmtEncoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	return e.nested(key, func() error {
+		return marshaler.MarshalLogArray(&logfmtArrayEncoder{enc: e})
+	})
+}
+
+func (e *logfmtEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	return e.nested(key, func() error {
+		return marshaler.MarshalLogObject(e)
+	})
+}
+
+func (e *logfmtEncoder) AddBinary(key string, value []byte) {
+	e.AddString(key, base64.StdEncoding.EncodeToString(value))
+}
+
+func (e *logfmtEncoder) AddByteString(key string, value []byte) {
+	e.AddString(key, string(value))
+}
+
+func (e *logfmtEncoder) AddBool(key string, value bool) {
+	e.addKey(key)
+	e.buf.AppendBool(value)
+}
+
+func (e *logfmtEncoder) AddComplex128(key string, value complex128) {
+	e.addKey(key)
+	e.buf.AppendString(strconv.FormatComplex(value, 'g', -1, 128))
+}
+
+func (e *logfmtEncoder) AddComplex64(key string, value complex64) {
+	e.addKey(key)
+	e.buf.AppendString(strconv.FormatComplex(complex128(value), 'g', -1, 64))
+}
+
+func (e *logfmtEncoder) AddDuration(key string, value time.Duration) {
+	e.addKey(key)
+	e.appendDuration(value)
+}
+
+func (e *logfmtEncoder) AddFloat64(key string, value float64) {
+	e.addKey(key)
+	e.appendFloat(value, 64)
+}
+
+func (e *logfmtEncoder) AddFloat32(key string, value float32) {
+	e.addKey(key)
+	e.appendFloat(float64(value), 32)
+}
+
+func (e *logfmtEncoder) AddInt(key string, value int)     { e.AddInt64(key, int64(value)) }
+func (e *logfmtEncoder) AddInt32(key string, value int32) { e.AddInt64(key, int64(value)) }
+func (e *logfmtEncoder) AddInt16(key string, value int16) { e.AddInt64(key, int64(value)) }
+func (e *logfmtEncoder) AddInt8(key string, value int8)   { e.AddInt64(key, int64(value)) }
+
+func (e *logfmtEncoder) AddInt64(key string, value int64) {
+	e.addKey(key)
+	e.buf.AppendInt(value)
+}
+
+func (e *logfmtEncoder) AddString(key, value string) {
+	e.addKey(key)
+	e.appendString(value)
+}
+
+func (e *logfmtEncoder) AddTime(key string, value time.Time) {
+	e.addKey(key)
+	e.appendTime(value)
+}
+
+func (e *logfmtEncoder) AddUint(key string, value uint)       { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUint32(key string, value uint32)   { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUint16(key string, value uint16)   { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUint8(key string, value uint8)     { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUintptr(key string, value uintptr) { e.AddUint64(key, uint64(value)) }
+
+func (e *logfmtEncoder) AddUint64(key string, value uint64) {
+	e.addKey(key)
+	e.buf.AppendUint(value)
+}
+
+// AddReflected encodes value as JSON and flattens the result like objects
+// and arrays
+func (e *logfmtEncoder) AddReflected(key string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return err
+	}
+
+	e.addJSON(key, decoded)
+	return nil
+}
+
+func (e *logfmtEncoder) addJSON(key string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		_ = e.nested(key, func() error {
+			for _, k := range keys {
+				e.addJSON(k, v[k])
+			}
+			return nil
+		})
+	case []interface{}:
+		_ = e.nested(key, func() error {
+			for index, item := range v {
+				e.addJSON(strconv.Itoa(index), item)
+			}
+			return nil
+		})
+	case json.Number:
+		e.addKey(key)
+		e.buf.AppendString(v.String())
+	case bool:
+		e.AddBool(key, v)
+	case string:
+		e.AddString(key, v)
+	default:
+		e.addKey(key)
+		e.buf.AppendString("null")
+	}
+}
+
+// OpenNamespace prefixes the keys of the following pairs with key
+func (e *logfmtEncoder) OpenNamespace(key string) {
+	e.prefix += key + "."
+}
+
+func (e *logfmtEncoder) appendFloat(value float64, bitSize int) {
+	switch {
+	case math.IsNaN(value):
+		e.buf.AppendString("NaN")
+	case math.IsInf(value, 1):
+		e.buf.AppendString("+Inf")
+	case math.IsInf(value, -1):
+		e.buf.AppendString("-Inf")
+	default:
+		e.buf.AppendFloat(value, bitSize)
+	}
+}
+
+func (e *logfmtEncoder) appendTime(value time.Time) {
+	if e.EncodeTime == nil {
+		e.buf.AppendInt(value.UnixNano())
+		return
+	}
+
+	e.EncodeTime(value, logfmtValueEncoder{e})
+}
+
+func (e *logfmtEncoder) appendDuration(value time.Duration) {
+	if e.EncodeDuration == nil {
+		e.buf.AppendInt(int64(value))
+		return
+	}
+
+	e.EncodeDuration(value, logfmtValueEncoder{e})
+}
+
+// logfmtValueEncoder writes the value of the current pair for the time,
+// duration, level, name and caller encoders of the config
+type logfmtValueEncoder struct {
+	enc *logfmtEncoder
+}
+
+func (v logfmtValueEncoder) AppendBool(value bool)         { v.enc.buf.AppendBool(value) }
+func (v logfmtValueEncoder) AppendByteString(value []byte) { v.enc.appendString(string(value)) }
+func (v logfmtValueEncoder) AppendComplex128(value complex128) {
+	v.enc.buf.AppendString(strconv.FormatComplex(value, 'g', -1, 128))
+}
+func (v logfmtValueEncoder) AppendComplex64(value complex64) {
+	v.enc.buf.AppendString(strconv.FormatComplex(complex128(value), 'g', -1, 64))
+}
+func (v logfmtValueEncoder) AppendFloat64(value float64)        { v.enc.appendFloat(value, 64) }
+func (v logfmtValueEncoder) AppendFloat32(value float32)        { v.enc.appendFloat(float64(value), 32) }
+func (v logfmtValueEncoder) AppendInt(value int)                { v.enc.buf.AppendInt(int64(value)) }
+func (v logfmtValueEncoder) AppendInt64(value int64)            { v.enc.buf.AppendInt(value) }
+func (v logfmtValueEncoder) AppendInt32(value int32)            { v.enc.buf.AppendInt(int64(value)) }
+func (v logfmtValueEncoder) AppendInt16(value int16)            { v.enc.buf.AppendInt(int64(value)) }
+func (v logfmtValueEncoder) AppendInt8(value int8)              { v.enc.buf.AppendInt(int64(value)) }
+func (v logfmtValueEncoder) AppendString(value string)          { v.enc.appendString(value) }
+func (v logfmtValueEncoder) AppendUint(value uint)              { v.enc.buf.AppendUint(uint64(value)) }
+func (v logfmtValueEncoder) AppendUint64(value uint64)          { v.enc.buf.AppendUint(value) }
+func (v logfmtValueEncoder) AppendUint32(value uint32)          { v.enc.buf.AppendUint(uint64(value)) }
+func (v logfmtValueEncoder) AppendUint16(value uint16)          { v.enc.buf.AppendUint(uint64(value)) }
+func (v logfmtValueEncoder) AppendUint8(value uint8)            { v.enc.buf.AppendUint(uint64(value)) }
+func (v logfmtValueEncoder) AppendUintptr(value uintptr)        { v.enc.buf.AppendUint(uint64(value)) }
+func (v logfmtValueEncoder) AppendDuration(value time.Duration) { v.enc.appendDuration(value) }
+func (v logfmtValueEncoder) AppendTime(value time.Time)         { v.enc.appendTime(value) }
+
+// logfmtArrayEncoder adds the elements of an array as pairs keyed by their
+// index
+type logfmtArrayEncoder struct {
+	enc   *logfmtEncoder
+	index int
+}
+
+func (a *logfmtArrayEncoder) key() string {
+	key := strconv.Itoa(a.index)
+	a.index++
+	return key
+}
+
+func (a *logfmtArrayEncoder) AppendBool(value bool)              { a.enc.AddBool(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendByteString(value []byte)      { a.enc.AddByteString(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendComplex128(value complex128)  { a.enc.AddComplex128(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendComplex64(value complex64)    { a.enc.AddComplex64(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendFloat64(value float64)        { a.enc.AddFloat64(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendFloat32(value float32)        { a.enc.AddFloat32(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendInt(value int)                { a.enc.AddInt(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendInt64(value int64)            { a.enc.AddInt64(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendInt32(value int32)            { a.enc.AddInt32(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendInt16(value int16)            { a.enc.AddInt16(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendInt8(value int8)              { a.enc.AddInt8(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendString(value string)          { a.enc.AddString(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendUint(value uint)              { a.enc.AddUint(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendUint64(value uint64)          { a.enc.AddUint64(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendUint32(value uint32)          { a.enc.AddUint32(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendUint16(value uint16)          { a.enc.AddUint16(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendUint8(value uint8)            { a.enc.AddUint8(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendUintptr(value uintptr)        { a.enc.AddUintptr(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendDuration(value time.Duration) { a.enc.AddDuration(a.key(), value) }
+func (a *logfmtArrayEncoder) AppendTime(value time.Time)         { a.enc.AddTime(a.key(), value) }
+
+func (a *logfmtArrayEncoder) AppendArray(marshaler zapcore.ArrayMarshaler) error {
+	return a.enc.AddArray(a.key(), marshaler)
+}
+
+func (a *logfmtArrayEncoder) AppendObject(marshaler zapcore.ObjectMarshaler) error {
+	return a.enc.AddObject(a.key(), marshaler)
+}
+
+func (a *logfmtArrayEncoder) AppendReflected(value interface{}) error {
+	return a.enc.AddReflected(a.key(), value)
+}
@@ -0,0 +1,359 @@
+package log
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// otelSeverityNumber maps levels to OpenTelemetry severity numbers
+func otelSeverityNumber(level zapcore.Level) int {
+	switch {
+	case level < zapcore.DebugLevel:
+		return 1
+	case level == zapcore.DebugLevel:
+		return 5
+	case level == zapcore.InfoLevel:
+		return 9
+	case level == zapcore.WarnLevel:
+		return 13
+	case level == zapcore.ErrorLevel:
+		return 17
+	case level == zapcore.DPanicLevel:
+		return 19
+	case level == zapcore.PanicLevel:
+		return 21
+	default:
+		return 24
+	}
+}
+
+// otelSeverityText is the LogLevel name of a level
+func otelSeverityText(level zapcore.Level) string {
+	if level == zapTraceLevel {
+		return LevelTrace.String()
+	}
+
+	return strings.ToUpper(level.String())
+}
+
+// otelTraceFlags are the W3C trace flags of a trace
+func otelTraceFlags(trace zapTrace) int {
+	if trace.Sampled {
+		return 1
+	}
+
+	return 0
+}
+
+// otelEncoder renders entries in the OpenTelemetry log data model, one JSON
+// object per line. Static fields become the Resource, the other fields and
+// the caller become the Attributes.
+type otelEncoder struct {
+	documentEncoder
+	config EncoderConfig
+}
+
+func newOTelEncoder(config EncoderConfig, resource []zapcore.Field) otelEncoder {
+	documentConfig := config
+	documentConfig.Namespace = "Attributes"
+
+	header := zapcore.EncoderConfig{
+		TimeKey:        "Timestamp",
+		LevelKey:       "SeverityText",
+		MessageKey:     "Body",
+		EncodeLevel:    capitalLevelEncoder,
+		EncodeTime:     otelTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+	}
+
+	split := func(entry zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, []zapcore.Field) {
+		top := make([]zapcore.Field, 0, 7)
+		top = append(top,
+			zap.String("ObservedTimestamp", strconv.FormatInt(time.Now().UnixNano(), 10)),
+			zap.Int("SeverityNumber", otelSeverityNumber(entry.Level)),
+		)
+		if entry.LoggerName != "" {
+			top = append(top, zap.Object("InstrumentationScope", otelScope(entry.LoggerName)))
+		}
+		if len(resource) > 0 {
+			top = append(top, zap.Object("Resource", zapDict(resource)))
+		}
+
+		for index, field := range fields {
+			if trace, ok := field.Interface.(zapTrace); ok && field.Type == zapcore.InlineMarshalerType {
+				top = append(top, zap.String("TraceId", trace.TraceID))
+				if trace.SpanID != "" {
+					top = append(top,
+						zap.String("SpanId", trace.SpanID),
+						zap.Int("TraceFlags", otelTraceFlags(trace)),
+					)
+				}
+				fields = withoutField(fields, index)
+				break
+			}
+		}
+
+		// ahead of the fields, which may open a namespace
+		if entry.Caller.Defined {
+			fields = append([]zapcore.Field{
+				zap.String("code.filepath", entry.Caller.File),
+				zap.Int("code.lineno", entry.Caller.Line),
+				zap.String("code.function", entry.Caller.Function),
+			}, fields...)
+		}
+
+		return top, fields
+	}
+
+	return otelEncoder{documentEncoder: newDocumentEncoder(documentConfig, header, split), config: config}
+}
+
+// otelTimeEncoder writes nanoseconds since the epoch as a decimal string,
+// JSON numbers lose precision beyond 2^53
+func otelTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(strconv.FormatInt(t.UnixNano(), 10))
+}
+
+func (e otelEncoder) withResource(static []zapcore.Field) zapcore.Encoder {
+	return newOTelEncoder(e.config, static)
+}
+
+type otelScope string
+
+func (s otelScope) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("Name", string(s))
+	return nil
+}
+
+var otlpBufferPool = buffer.NewPool()
+
+// otlpEncoder renders entries as OTLP/JSON log records for an OTLPExporter.
+// Every record is preceded by the resource of the encoder, the static fields,
+// on a line of its own.
+type otlpEncoder struct {
+	*mapEncoder
+	resource []byte
+	// report is given the resource of the first logger, nil for encoders
+	// without a report encoder
+	report *otlpReportResource
+}
+
+func newOTLPEncoder() otlpEncoder {
+	return otlpEncoder{mapEncoder: newMapEncoder()}
+}
+
+func (e otlpEncoder) withResource(static []zapcore.Field) zapcore.Encoder {
+	final := e.clone()
+	if len(static) > 0 {
+		resource := zapcore.NewMapObjectEncoder()
+		for _, field := range static {
+			field.AddTo(resource)
+		}
+		final.resource, _ = json.Marshal(newOTLPAttributes(resource.Fields))
+	}
+
+	if e.report != nil {
+		e.report.set(final.resource)
+	}
+
+	return final
+}
+
+func (e otlpEncoder) Clone() zapcore.Encoder {
+	return e.clone()
+}
+
+func (e otlpEncoder) clone() otlpEncoder {
+	return otlpEncoder{mapEncoder: e.mapEncoder.clone(), resource: e.resource, report: e.report}
+}
+
+func (e otlpEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := e.clone()
+
+	record := otlpLogRecord{
+		TimeUnixNano:         strconv.FormatInt(entry.Time.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber:       otelSeverityNumber(entry.Level),
+		SeverityText:         otelSeverityText(entry.Level),
+		Body:                 newOTLPAnyValue(entry.Message),
+	}
+
+	for _, field := range fields {
+		if trace, ok := field.Interface.(zapTrace); ok && field.Type == zapcore.InlineMarshalerType {
+			record.TraceID, record.SpanID = trace.TraceID, trace.SpanID
+			if trace.SpanID != "" {
+				record.Flags = otelTraceFlags(trace)
+			}
+			continue
+		}
+
+		field.AddTo(final)
+	}
+
+	if entry.LoggerName != "" {
+		final.Fields["logger.name"] = entry.LoggerName
+	}
+	if entry.Caller.Defined {
+		final.Fields["code.filepath"] = entry.Caller.File
+		final.Fields["code.lineno"] = entry.Caller.Line
+		final.Fields["code.function"] = entry.Caller.Function
+	}
+	record.Attributes = newOTLPAttributes(final.Fields)
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := otlpBufferPool.Get()
+	buf.Write(e.resource)
+	buf.AppendByte('\n')
+	buf.Write(b)
+
+	return buf, nil
+}
+
+// otlpReportResource is the resource of the warnings about dropped records,
+// the static fields of the first logger using the output
+type otlpReportResource struct {
+	mutex    sync.Mutex
+	found    bool
+	resource []byte
+}
+
+func (r *otlpReportResource) set(resource []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.found {
+		r.found, r.resource = true, resource
+	}
+}
+
+func (r *otlpReportResource) get() []byte {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.resource
+}
+
+// otlpReportEncoder encodes the warnings about dropped records of an output
+// with the resource of the loggers using it
+type otlpReportEncoder struct {
+	otlpEncoder
+}
+
+func (e otlpReportEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := e.otlpEncoder
+	final.resource = e.report.get()
+
+	return final.EncodeEntry(entry, fields)
+}
+
+// otlpLogRecord is a LogRecord of the OTLP/JSON encoding, 64 bit integers are
+// strings and ids are hex like the protocol requires
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+	Flags                int            `json:"flags,omitempty"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string           `json:"stringValue,omitempty"`
+	BoolValue   *bool             `json:"boolValue,omitempty"`
+	IntValue    *string           `json:"intValue,omitempty"`
+	DoubleValue *float64          `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKeyValueList `json:"kvlistValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKeyValueList struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// newOTLPAttributes converts the fields collected by a MapObjectEncoder,
+// sorted by key
+func newOTLPAttributes(fields map[string]interface{}) []otlpKeyValue {
+	attributes := make([]otlpKeyValue, 0, len(fields))
+	for key, value := range fields {
+		attributes = append(attributes, otlpKeyValue{Key: key, Value: newOTLPAnyValue(value)})
+	}
+
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Key < attributes[j].Key })
+
+	return attributes
+}
+
+func newOTLPAnyValue(value interface{}) otlpAnyValue {
+	stringValue := func(s string) otlpAnyValue { return otlpAnyValue{StringValue: &s} }
+
+	switch v := value.(type) {
+	case string:
+		return stringValue(v)
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case []byte:
+		return stringValue(base64.StdEncoding.EncodeToString(v))
+	case time.Time:
+		return stringValue(v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return stringValue(v.String())
+	case []interface{}:
+		values := make([]otlpAnyValue, 0, len(v))
+		for _, item := range v {
+			values = append(values, newOTLPAnyValue(item))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case map[string]interface{}:
+		return otlpAnyValue{KvlistValue: &otlpKeyValueList{Values: newOTLPAttributes(v)}}
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s := strconv.FormatInt(rv.Int(), 10)
+		return otlpAnyValue{IntValue: &s}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return stringValue(strconv.FormatUint(rv.Uint(), 10))
+		}
+		s := strconv.FormatUint(rv.Uint(), 10)
+		return otlpAnyValue{IntValue: &s}
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			// JSON has no NaN and infinities
+			return stringValue(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		return otlpAnyValue{DoubleValue: &f}
+	default:
+		return stringValue(fmt.Sprint(value))
+	}
+}
@@ -0,0 +1,236 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var syslogBufferPool = buffer.NewPool()
+
+// mapEncoder is a zapcore.MapObjectEncoder whose clones keep the namespaces
+// opened by fields
+type mapEncoder struct {
+	*zapcore.MapObjectEncoder
+	namespaces []string
+}
+
+func newMapEncoder() *mapEncoder {
+	return &mapEncoder{MapObjectEncoder: zapcore.NewMapObjectEncoder()}
+}
+
+func (m *mapEncoder) OpenNamespace(key string) {
+	m.MapObjectEncoder.OpenNamespace(key)
+	m.namespaces = append(m.namespaces[:len(m.namespaces):len(m.namespaces)], key)
+}
+
+// clone copies the maps of the open namespaces, which later fields are added
+// to. The maps of objects are never changed once added, so they are shared.
+func (m *mapEncoder) clone() *mapEncoder {
+	clone := &mapEncoder{MapObjectEncoder: zapcore.NewMapObjectEncoder(), namespaces: m.namespaces}
+
+	current, source := clone.Fields, m.Fields
+	for _, namespace := range m.namespaces {
+		for key, value := range source {
+			current[key] = value
+		}
+
+		// replaces the map of the namespace copied above
+		clone.MapObjectEncoder.OpenNamespace(namespace)
+		current = current[namespace].(map[string]interface{})
+		source, _ = source[namespace].(map[string]interface{})
+	}
+	for key, value := range source {
+		current[key] = value
+	}
+
+	return clone
+}
+
+// syslogEncoder renders entries as RFC 5424 or RFC 3164 messages without
+// transport framing. Fields are collected in a map, with nested objects and
+// namespaces flattened to dotted keys.
+type syslogEncoder struct {
+	*mapEncoder
+	config SyslogConfig
+	pid    string
+}
+
+func newSyslogEncoder(config SyslogConfig) syslogEncoder {
+	return syslogEncoder{
+		mapEncoder: newMapEncoder(),
+		config:     config,
+		pid:        strconv.Itoa(os.Getpid()),
+	}
+}
+
+func (e syslogEncoder) Clone() zapcore.Encoder {
+	return e.clone()
+}
+
+func (e syslogEncoder) clone() syslogEncoder {
+	return syslogEncoder{mapEncoder: e.mapEncoder.clone(), config: e.config, pid: e.pid}
+}
+
+func (e syslogEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := e.clone()
+	for _, field := range fields {
+		field.AddTo(final)
+	}
+	if entry.Caller.Defined {
+		final.Fields["caller"] = entry.Caller.TrimmedPath()
+	}
+
+	params := flattenSyslogParams("", final.Fields, nil)
+	sort.Slice(params, func(i, j int) bool { return params[i].name < params[j].name })
+
+	buf := syslogBufferPool.Get()
+	priority := e.config.Facility.code()*8 + syslogSeverity(entry.Level)
+
+	if e.config.Format == RFC3164 {
+		buf.AppendString(fmt.Sprintf("<%d>%s %s %s[%s]: ", priority, entry.Time.Format(time.Stamp),
+			e.config.Hostname, e.config.AppName, e.pid))
+		buf.AppendString(entry.Message)
+		for _, param := range params {
+			buf.AppendByte(' ')
+			buf.AppendString(param.name)
+			buf.AppendByte('=')
+			// quoting escapes control characters, so a value stays on one line
+			if needsLogfmtQuote(param.value) {
+				buf.AppendString(strconv.Quote(param.value))
+			} else {
+				buf.AppendString(param.value)
+			}
+		}
+
+		return buf, nil
+	}
+
+	msgID := "-"
+	if entry.LoggerName != "" {
+		msgID = syslogHeaderField(entry.LoggerName, 32)
+	}
+
+	buf.AppendString(fmt.Sprintf("<%d>1 %s %s %s %s %s ", priority, entry.Time.Format(syslogTimeFormat),
+		syslogHeaderField(e.config.Hostname, 255), syslogHeaderField(e.config.AppName, 48), e.pid, msgID))
+
+	if len(params) == 0 {
+		buf.AppendByte('-')
+	} else {
+		buf.AppendByte('[')
+		buf.AppendString(e.config.StructuredDataID)
+		for _, param := range params {
+			buf.AppendByte(' ')
+			buf.AppendString(param.name)
+			buf.AppendString(`="`)
+			buf.AppendString(syslogParamEscaper.Replace(param.value))
+			buf.AppendByte('"')
+		}
+		buf.AppendByte(']')
+	}
+
+	if entry.Message != "" {
+		buf.AppendByte(' ')
+		buf.AppendString(entry.Message)
+	}
+
+	return buf, nil
+}
+
+const syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// syslogParamEscaper escapes the characters RFC 5424 forbids unescaped in
+// structured data values
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogSeverity maps zap levels to syslog severities, trace and debug share
+// the lowest one
+func syslogSeverity(level zapcore.Level) int {
+	switch {
+	case level <= zapcore.DebugLevel:
+		return 7
+	case level == zapcore.InfoLevel:
+		return 6
+	case level == zapcore.WarnLevel:
+		return 4
+	case level == zapcore.ErrorLevel:
+		return 3
+	case level == zapcore.DPanicLevel:
+		return 2
+	case level == zapcore.PanicLevel:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// syslogHeaderField replaces the characters not allowed in header fields and
+// structured data names and truncates s to limit bytes, empty values become
+// the nil value
+func syslogHeaderField(s string, limit int) string {
+	if s == "" {
+		return "-"
+	}
+
+	b := []byte(s)
+	if len(b) > limit {
+		b = b[:limit]
+	}
+	for index, c := range b {
+		if c < 33 || c > 126 || c == '=' || c == ']' || c == '"' {
+			b[index] = '_'
+		}
+	}
+
+	return string(b)
+}
+
+type syslogParam struct {
+	name  string
+	value string
+}
+
+// flattenSyslogParams appends the fields as params, nested objects are
+// flattened to dotted names and arrays are rendered as JSON
+func flattenSyslogParams(prefix string, fields map[string]interface{}, params []syslogParam) []syslogParam {
+	for key, value := range fields {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			params = flattenSyslogParams(key, nested, params)
+			continue
+		}
+
+		params = append(params, syslogParam{name: syslogHeaderField(key, 32), value: syslogValue(value)})
+	}
+
+	return params
+}
+
+func syslogValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,83 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// maxErrorCauses bounds the causes walked for a single error field
+const maxErrorCauses = 32
+
+// LogFielder is implemented by errors that carry structured context. Error
+// fields log the fields of every error in the chain along with the message.
+type LogFielder interface {
+	LogFields() []Field
+}
+
+// errorCause describes an error found in the chain of an error field
+type errorCause struct {
+	message   string
+	errorType string
+}
+
+// errorDetail is what an error field logs beyond err.Error()
+type errorDetail struct {
+	errorType string
+	causes    []errorCause
+	stack     string
+	fields    []Field
+}
+
+// newErrorDetail walks the errors.Unwrap chain and the errors.Join tree of err
+// depth first
+func newErrorDetail(err error) errorDetail {
+	detail := errorDetail{errorType: fmt.Sprintf("%T", err)}
+
+	stackDepth := -1
+	var walk func(err error, depth int)
+	walk = func(err error, depth int) {
+		if err == nil || len(detail.causes) >= maxErrorCauses {
+			return
+		}
+
+		if depth > 0 {
+			detail.causes = append(detail.causes, errorCause{message: err.Error(), errorType: fmt.Sprintf("%T", err)})
+		}
+
+		// the deepest stack is closest to where the failure happened, the first
+		// one found wins among stacks at the same depth of a tree
+		if depth > stackDepth {
+			if stack := errorStack(err); stack != "" {
+				detail.stack, stackDepth = stack, depth
+			}
+		}
+
+		if fielder, ok := err.(LogFielder); ok {
+			detail.fields = mergeFields(detail.fields, fielder.LogFields())
+		}
+
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, cause := range e.Unwrap() {
+				walk(cause, depth+1)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap(), depth+1)
+		}
+	}
+	walk(err, 0)
+
+	return detail
+}
+
+// errorStack returns the stack trace carried by errors with a StackTrace
+// method, like the ones from github.com/pkg/errors, formatted with %+v
+func errorStack(err error) string {
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return ""
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("%+v", method.Call(nil)[0].Interface()))
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"fmt"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// zapError is the inline zapcore.ObjectMarshaler of an error field. For the key
+// "error" it adds:
+//
+//	error       err.Error()
+//	errorType   the concrete type of err
+//	errorCauses the wrapped and joined errors with their concrete types
+//	errorStack  the stack trace carried by the error chain, if any
+//	errorFields the fields of errors implementing LogFielder, if any
+//
+// Errors implementing fmt.Formatter without a stack trace keep their verbose
+// form under errorVerbose.
+type zapError struct {
+	key    string
+	err    error
+	logger ZapLogger
+}
+
+func (e zapError) MarshalLogObject(enc zapcore.ObjectEncoder) (err error) {
+	// errors with a nil receiver may panic in Error
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			enc.AddString(e.key, fmt.Sprintf("PANIC=%v", recovered))
+		}
+	}()
+
+	message := e.err.Error()
+	enc.AddString(e.key, message)
+
+	detail := newErrorDetail(e.err)
+	enc.AddString(e.key+"Type", detail.errorType)
+
+	if len(detail.causes) > 0 {
+		err = enc.AddArray(e.key+"Causes", zapErrorCauses(detail.causes))
+	}
+
+	if detail.stack != "" {
+		enc.AddString(e.key+"Stack", detail.stack)
+	} else if _, ok := e.err.(fmt.Formatter); ok {
+		if verbose := fmt.Sprintf("%+v", e.err); verbose != message {
+			enc.AddString(e.key+"Verbose", verbose)
+		}
+	}
+
+	if len(detail.fields) > 0 {
+		if fieldsErr := enc.AddObject(e.key+"Fields", zapDict(e.logger.parseFields(detail.fields))); err == nil {
+			err = fieldsErr
+		}
+	}
+
+	return err
+}
+
+type zapErrorCauses []errorCause
+
+func (c zapErrorCauses) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, cause := range c {
+		if err := enc.AppendObject(cause); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c errorCause) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("error", c.message)
+	enc.AddString("type", c.errorType)
+	return nil
+}
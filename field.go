@@ -2,6 +2,7 @@ package log
 
 import (
 	"fmt"
+	"math"
 	"runtime"
 	"strconv"
 	"strings"
@@ -116,17 +117,34 @@ const (
 	UintsType
 	// UintptrsType indicates that the field carries a uintptr slice.
 	UintptrsType
+
+	// DictType indicates that the field carries a group of fields.
+	DictType
+	// TimeFullType indicates that the field carries a time.Time stored as-is.
+	TimeFullType
 )
 
 // A Field is a marshaling operation used to add a key-value pair to a logger's
 // context. Most fields are lazily marshaled, so it's inexpensive to add fields
 // to disabled debug-level log statements.
+//
+// Like zapcore.Field, numeric, boolean and duration values are stored in
+// Integer and strings in String, so constructing them doesn't allocate. Only
+// the remaining types are boxed in Interface.
 type Field struct {
-	Key   string
-	Type  FieldType
-	Value interface{}
+	Key       string
+	Type      FieldType
+	Integer   int64
+	String    string
+	Interface interface{}
 }
 
+// Limits of the times representable by UnixNano.
+var (
+	minTimeInt64 = time.Unix(0, math.MinInt64)
+	maxTimeInt64 = time.Unix(0, math.MaxInt64)
+)
+
 // Skip constructs a no-op field, which is often useful when handling invalid
 // inputs in other Field constructors.
 func Skip() Field {
@@ -145,12 +163,16 @@ func nilField(key string) Field { return Reflect(key, nil) }
 // zap's JSON encoder base64-encodes binary blobs. To log UTF-8 encoded text,
 // use ByteString.
 func Binary(key string, val []byte) Field {
-	return Field{Key: key, Type: BinaryType, Value: val}
+	return Field{Key: key, Type: BinaryType, Interface: val}
 }
 
 // Bool constructs a field that carries a bool.
 func Bool(key string, val bool) Field {
-	return Field{Key: key, Type: BoolType, Value: val}
+	var integer int64
+	if val {
+		integer = 1
+	}
+	return Field{Key: key, Type: BoolType, Integer: integer}
 }
 
 // Boolp constructs a field that carries a *bool. The returned Field will safely
@@ -166,14 +188,14 @@ func Boolp(key string, val *bool) Field {
 // To log opaque binary blobs (which aren't necessarily valid UTF-8), use
 // Binary.
 func ByteString(key string, val []byte) Field {
-	return Field{Key: key, Type: ByteStringType, Value: val}
+	return Field{Key: key, Type: ByteStringType, Interface: val}
 }
 
 // Complex128 constructs a field that carries a complex number. Unlike most
 // numeric fields, this costs an allocation (to convert the complex128 to
 // interface{}).
 func Complex128(key string, val complex128) Field {
-	return Field{Key: key, Type: Complex128Type, Value: val}
+	return Field{Key: key, Type: Complex128Type, Interface: val}
 }
 
 // Complex128p constructs a field that carries a *complex128. The returned Field will safely
@@ -189,7 +211,7 @@ func Complex128p(key string, val *complex128) Field {
 // numeric fields, this costs an allocation (to convert the complex64 to
 // interface{}).
 func Complex64(key string, val complex64) Field {
-	return Field{Key: key, Type: Complex64Type, Value: val}
+	return Field{Key: key, Type: Complex64Type, Interface: val}
 }
 
 // Complex64p constructs a field that carries a *complex64. The returned Field will safely
@@ -205,7 +227,7 @@ func Complex64p(key string, val *complex64) Field {
 // floating-point value is represented is encoder-dependent, so marshaling is
 // necessarily lazy.
 func Float64(key string, val float64) Field {
-	return Field{Key: key, Type: Float64Type, Value: val}
+	return Field{Key: key, Type: Float64Type, Integer: int64(math.Float64bits(val))}
 }
 
 // Float64p constructs a field that carries a *float64. The returned Field will safely
@@ -221,7 +243,7 @@ func Float64p(key string, val *float64) Field {
 // floating-point value is represented is encoder-dependent, so marshaling is
 // necessarily lazy.
 func Float32(key string, val float32) Field {
-	return Field{Key: key, Type: Float32Type, Value: val}
+	return Field{Key: key, Type: Float32Type, Integer: int64(math.Float32bits(val))}
 }
 
 // Float32p constructs a field that carries a *float32. The returned Field will safely
@@ -235,7 +257,7 @@ func Float32p(key string, val *float32) Field {
 
 // Int constructs a field with the given key and value.
 func Int(key string, val int) Field {
-	return Field{Key: key, Type: IntType, Value: val}
+	return Field{Key: key, Type: IntType, Integer: int64(val)}
 }
 
 // Intp constructs a field that carries a *int. The returned Field will safely
@@ -249,7 +271,7 @@ func Intp(key string, val *int) Field {
 
 // Int64 constructs a field with the given key and value.
 func Int64(key string, val int64) Field {
-	return Field{Key: key, Type: Int64Type, Value: val}
+	return Field{Key: key, Type: Int64Type, Integer: val}
 }
 
 // Int64p constructs a field that carries a *int64. The returned Field will safely
@@ -263,7 +285,7 @@ func Int64p(key string, val *int64) Field {
 
 // Int32 constructs a field with the given key and value.
 func Int32(key string, val int32) Field {
-	return Field{Key: key, Type: Int32Type, Value: val}
+	return Field{Key: key, Type: Int32Type, Integer: int64(val)}
 }
 
 // Int32p constructs a field that carries a *int32. The returned Field will safely
@@ -277,7 +299,7 @@ func Int32p(key string, val *int32) Field {
 
 // Int16 constructs a field with the given key and value.
 func Int16(key string, val int16) Field {
-	return Field{Key: key, Type: Int16Type, Value: val}
+	return Field{Key: key, Type: Int16Type, Integer: int64(val)}
 }
 
 // Int16p constructs a field that carries a *int16. The returned Field will safely
@@ -291,7 +313,7 @@ func Int16p(key string, val *int16) Field {
 
 // Int8 constructs a field with the given key and value.
 func Int8(key string, val int8) Field {
-	return Field{Key: key, Type: Int8Type, Value: val}
+	return Field{Key: key, Type: Int8Type, Integer: int64(val)}
 }
 
 // Int8p constructs a field that carries a *int8. The returned Field will safely
@@ -305,7 +327,7 @@ func Int8p(key string, val *int8) Field {
 
 // String constructs a field with the given key and value.
 func String(key string, val string) Field {
-	return Field{Key: key, Type: StringType, Value: val}
+	return Field{Key: key, Type: StringType, String: val}
 }
 
 // Stringp constructs a field that carries a *string. The returned Field will safely
@@ -319,7 +341,7 @@ func Stringp(key string, val *string) Field {
 
 // Uint constructs a field with the given key and value.
 func Uint(key string, val uint) Field {
-	return Field{Key: key, Type: UintType, Value: val}
+	return Field{Key: key, Type: UintType, Integer: int64(val)}
 }
 
 // Uintp constructs a field that carries a *uint. The returned Field will safely
@@ -333,7 +355,7 @@ func Uintp(key string, val *uint) Field {
 
 // Uint64 constructs a field with the given key and value.
 func Uint64(key string, val uint64) Field {
-	return Field{Key: key, Type: Uint64Type, Value: val}
+	return Field{Key: key, Type: Uint64Type, Integer: int64(val)}
 }
 
 // Uint64p constructs a field that carries a *uint64. The returned Field will safely
@@ -347,7 +369,7 @@ func Uint64p(key string, val *uint64) Field {
 
 // Uint32 constructs a field with the given key and value.
 func Uint32(key string, val uint32) Field {
-	return Field{Key: key, Type: Uint32Type, Value: val}
+	return Field{Key: key, Type: Uint32Type, Integer: int64(val)}
 }
 
 // Uint32p constructs a field that carries a *uint32. The returned Field will safely
@@ -361,7 +383,7 @@ func Uint32p(key string, val *uint32) Field {
 
 // Uint16 constructs a field with the given key and value.
 func Uint16(key string, val uint16) Field {
-	return Field{Key: key, Type: Uint16Type, Value: val}
+	return Field{Key: key, Type: Uint16Type, Integer: int64(val)}
 }
 
 // Uint16p constructs a field that carries a *uint16. The returned Field will safely
@@ -375,7 +397,7 @@ func Uint16p(key string, val *uint16) Field {
 
 // Uint8 constructs a field with the given key and value.
 func Uint8(key string, val uint8) Field {
-	return Field{Key: key, Type: Uint8Type, Value: val}
+	return Field{Key: key, Type: Uint8Type, Integer: int64(val)}
 }
 
 // Uint8p constructs a field that carries a *uint8. The returned Field will safely
@@ -389,7 +411,7 @@ func Uint8p(key string, val *uint8) Field {
 
 // Uintptr constructs a field with the given key and value.
 func Uintptr(key string, val uintptr) Field {
-	return Field{Key: key, Type: UintptrType, Value: val}
+	return Field{Key: key, Type: UintptrType, Integer: int64(val)}
 }
 
 // Uintptrp constructs a field that carries a *uintptr. The returned Field will safely
@@ -409,7 +431,15 @@ func Uintptrp(key string, val *uintptr) Field {
 // If encoding fails (e.g., trying to serialize a map[int]string to JSON), Reflect
 // includes the error message in the final log output.
 func Reflect(key string, val interface{}) Field {
-	return Field{Key: key, Type: ReflectType, Value: val}
+	return Field{Key: key, Type: ReflectType, Interface: val}
+}
+
+// Object constructs a field with the given key and ObjectMarshaler. It
+// provides a flexible, but still type-safe and efficient, way to add map- or
+// struct-like user-defined types to the logging context. The struct's
+// MarshalLogObject method is called lazily.
+func Object(key string, val ObjectMarshaler) Field {
+	return Field{Key: key, Type: ObjectMarshalerType, Interface: val}
 }
 
 // Namespace creates a named, isolated scope within the logger's context. All
@@ -421,16 +451,27 @@ func Namespace(key string) Field {
 	return Field{Key: key, Type: NamespaceType}
 }
 
+// Dict constructs a field that groups the given fields under key. Unlike
+// Namespace, only the given fields are grouped. The JSON encoder renders the
+// group as a nested object, the console encoder as dotted keys like
+// "http.method".
+func Dict(key string, fields ...Field) Field {
+	return Field{Key: key, Type: DictType, Interface: fields}
+}
+
 // Stringer constructs a field with the given key and the output of the value's
 // String method. The Stringer's String method is called lazily.
 func Stringer(key string, val fmt.Stringer) Field {
-	return Field{Key: key, Type: StringerType, Value: val}
+	return Field{Key: key, Type: StringerType, Interface: val}
 }
 
 // Time constructs a Field with the given key and value. The encoder
 // controls how the time is serialized.
 func Time(key string, val time.Time) Field {
-	return Field{Key: key, Type: TimeType, Value: val}
+	if val.Before(minTimeInt64) || val.After(maxTimeInt64) {
+		return Field{Key: key, Type: TimeFullType, Interface: val}
+	}
+	return Field{Key: key, Type: TimeType, Integer: val.UnixNano(), Interface: val.Location()}
 }
 
 // Timep constructs a field that carries a *time.Time. The returned Field will safely
@@ -469,7 +510,7 @@ func getStackFrames(skip int) (frames []string) {
 // Duration constructs a field with the given key and value. The encoder
 // controls how the duration is serialized.
 func Duration(key string, val time.Duration) Field {
-	return Field{Key: key, Type: DurationType, Value: val}
+	return Field{Key: key, Type: DurationType, Integer: int64(val)}
 }
 
 // Durationp constructs a field that carries a *time.Duration. The returned Field will safely
@@ -487,9 +528,14 @@ func Err(err error) Field {
 }
 
 // NamedError constructs a field that lazily stores err.Error() under the
-// provided key. Errors which also implement fmt.Formatter (like those produced
-// by github.com/pkg/errors) will also have their verbose representation stored
-// under key+"Verbose". If passed a nil error, the field is a no-op.
+// provided key. The concrete type of err is stored under key+"Type", the
+// errors found by walking its errors.Unwrap chain and errors.Join tree under
+// key+"Causes", a stack trace carried by the chain (like those produced by
+// github.com/pkg/errors) under key+"Stack", and the fields of errors
+// implementing LogFielder under key+"Fields". Errors which implement
+// fmt.Formatter but carry no stack trace will have their verbose
+// representation stored under key+"Verbose". If passed a nil error, the field
+// is a no-op.
 //
 // For the common case in which the key is simply "error", the Error function
 // is shorter and less repetitive.
@@ -497,7 +543,7 @@ func NamedError(key string, err error) Field {
 	if err == nil {
 		return Skip()
 	}
-	return Field{Key: key, Type: ErrorType, Value: err}
+	return Field{Key: key, Type: ErrorType, Interface: err}
 }
 
 // Any takes a key and an arbitrary value and chooses the best way to represent
@@ -509,10 +555,10 @@ func NamedError(key string, err error) Field {
 // values are treated as uint8, and runes are always treated as integers.
 func Any(key string, value interface{}) Field {
 	switch val := value.(type) {
-	// case zapcore.ObjectMarshaler:
-	// 	return Object(key, val)
-	// case zapcore.ArrayMarshaler:
-	// 	return Array(key, val)
+	case ObjectMarshaler:
+		return Object(key, val)
+	case ArrayMarshaler:
+		return Array(key, val)
 	case bool:
 		return Bool(key, val)
 	case *bool:
@@ -636,110 +682,189 @@ func Any(key string, value interface{}) Field {
 	}
 }
 
-// // Array constructs a field with the given key and ArrayMarshaler. It provides
-// // a flexible, but still type-safe and efficient, way to add array-like types
-// // to the logging context. The struct's MarshalLogArray method is called lazily.
-// func Array(key string, val ArrayMarshaler) Field {
-// 	return Field{Key: key, Type: ArrayMarshalerType, Value: val}
-// }
+// sweetenFields converts loosely typed key-value pairs to fields. Field values
+// are taken as they are. Dangling keys and pairs with a non-string key are
+// reported in an "invalid_kv" field instead of being logged as pairs.
+func sweetenFields(keyAndValues []interface{}) []Field {
+	if len(keyAndValues) == 0 {
+		return nil
+	}
+
+	fields := make([]Field, 0, len(keyAndValues))
+	var invalid invalidPairs
+
+	for index := 0; index < len(keyAndValues); index++ {
+		if field, ok := keyAndValues[index].(Field); ok {
+			fields = append(fields, field)
+			continue
+		}
+
+		if index+1 == len(keyAndValues) {
+			invalid = append(invalid, invalidPair{position: index, key: keyAndValues[index], dangling: true})
+			break
+		}
+
+		key, value := keyAndValues[index], keyAndValues[index+1]
+		index++
+
+		if keyString, ok := key.(string); ok {
+			fields = append(fields, Any(keyString, value))
+		} else {
+			invalid = append(invalid, invalidPair{position: index - 1, key: key, value: value})
+		}
+	}
+
+	if len(invalid) > 0 {
+		fields = append(fields, Array("invalid_kv", invalid))
+	}
+
+	return fields
+}
+
+// invalidPair is a key-value pair rejected by sweetenFields
+type invalidPair struct {
+	position int
+	key      interface{}
+	value    interface{}
+	dangling bool
+}
+
+func (p invalidPair) MarshalLogObject(enc ObjectEncoder) error {
+	enc.AddInt("position", p.position)
+	if p.dangling {
+		enc.AddString("reason", "dangling key")
+		return enc.AddReflected("key", p.key)
+	}
+
+	enc.AddString("reason", "non-string key")
+	if err := enc.AddReflected("key", p.key); err != nil {
+		return err
+	}
+	return enc.AddReflected("value", p.value)
+}
+
+type invalidPairs []invalidPair
+
+func (ps invalidPairs) MarshalLogArray(enc ArrayEncoder) error {
+	for _, p := range ps {
+		if err := enc.AppendObject(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Array constructs a field with the given key and ArrayMarshaler. It provides
+// a flexible, but still type-safe and efficient, way to add array-like types
+// to the logging context. The struct's MarshalLogArray method is called lazily.
+func Array(key string, val ArrayMarshaler) Field {
+	return Field{Key: key, Type: ArrayMarshalerType, Interface: val}
+}
+
+// Objects constructs a field with the given key, holding a list of the
+// provided objects that can be marshaled by the logger.
+func Objects[T ObjectMarshaler](key string, values []T) Field {
+	return Array(key, objects[T](values))
+}
 
 // Bools constructs a field that carries a slice of bools.
 func Bools(key string, bs []bool) Field {
-	return Field{Key: key, Type: BoolsType, Value: bs}
+	return Field{Key: key, Type: BoolsType, Interface: bs}
 }
 
 // ByteStrings constructs a field that carries a slice of []byte, each of which
 // must be UTF-8 encoded text.
 func ByteStrings(key string, bss [][]byte) Field {
-	return Field{Key: key, Type: ByteStringsType, Value: bss}
+	return Field{Key: key, Type: ByteStringsType, Interface: bss}
 }
 
 // Complex128s constructs a field that carries a slice of complex numbers.
 func Complex128s(key string, nums []complex128) Field {
-	return Field{Key: key, Type: Complex128sType, Value: nums}
+	return Field{Key: key, Type: Complex128sType, Interface: nums}
 }
 
 // Complex64s constructs a field that carries a slice of complex numbers.
 func Complex64s(key string, nums []complex64) Field {
-	return Field{Key: key, Type: Complex64sType, Value: nums}
+	return Field{Key: key, Type: Complex64sType, Interface: nums}
 }
 
 // Durations constructs a field that carries a slice of time.Durations.
 func Durations(key string, ds []time.Duration) Field {
-	return Field{Key: key, Type: DurationsType, Value: ds}
+	return Field{Key: key, Type: DurationsType, Interface: ds}
 }
 
 // Float64s constructs a field that carries a slice of floats.
 func Float64s(key string, nums []float64) Field {
-	return Field{Key: key, Type: Float64sType, Value: nums}
+	return Field{Key: key, Type: Float64sType, Interface: nums}
 }
 
 // Float32s constructs a field that carries a slice of floats.
 func Float32s(key string, nums []float32) Field {
-	return Field{Key: key, Type: Float32sType, Value: nums}
+	return Field{Key: key, Type: Float32sType, Interface: nums}
 }
 
 // Ints constructs a field that carries a slice of integers.
 func Ints(key string, nums []int) Field {
-	return Field{Key: key, Type: IntsType, Value: nums}
+	return Field{Key: key, Type: IntsType, Interface: nums}
 }
 
 // Int64s constructs a field that carries a slice of integers.
 func Int64s(key string, nums []int64) Field {
-	return Field{Key: key, Type: Int64sType, Value: nums}
+	return Field{Key: key, Type: Int64sType, Interface: nums}
 }
 
 // Int32s constructs a field that carries a slice of integers.
 func Int32s(key string, nums []int32) Field {
-	return Field{Key: key, Type: Int32sType, Value: nums}
+	return Field{Key: key, Type: Int32sType, Interface: nums}
 }
 
 // Int16s constructs a field that carries a slice of integers.
 func Int16s(key string, nums []int16) Field {
-	return Field{Key: key, Type: Int16sType, Value: nums}
+	return Field{Key: key, Type: Int16sType, Interface: nums}
 }
 
 // Int8s constructs a field that carries a slice of integers.
 func Int8s(key string, nums []int8) Field {
-	return Field{Key: key, Type: Int8sType, Value: nums}
+	return Field{Key: key, Type: Int8sType, Interface: nums}
 }
 
 // Strings constructs a field that carries a slice of strings.
 func Strings(key string, ss []string) Field {
-	return Field{Key: key, Type: StringsType, Value: ss}
+	return Field{Key: key, Type: StringsType, Interface: ss}
 }
 
 // Times constructs a field that carries a slice of time.Times.
 func Times(key string, ts []time.Time) Field {
-	return Field{Key: key, Type: TimesType, Value: ts}
+	return Field{Key: key, Type: TimesType, Interface: ts}
 }
 
 // Uints constructs a field that carries a slice of unsigned integers.
 func Uints(key string, nums []uint) Field {
-	return Field{Key: key, Type: UintsType, Value: nums}
+	return Field{Key: key, Type: UintsType, Interface: nums}
 }
 
 // Uint64s constructs a field that carries a slice of unsigned integers.
 func Uint64s(key string, nums []uint64) Field {
-	return Field{Key: key, Type: Uint64sType, Value: nums}
+	return Field{Key: key, Type: Uint64sType, Interface: nums}
 }
 
 // Uint32s constructs a field that carries a slice of unsigned integers.
 func Uint32s(key string, nums []uint32) Field {
-	return Field{Key: key, Type: Uint32sType, Value: nums}
+	return Field{Key: key, Type: Uint32sType, Interface: nums}
 }
 
 // Uint16s constructs a field that carries a slice of unsigned integers.
 func Uint16s(key string, nums []uint16) Field {
-	return Field{Key: key, Type: Uint16sType, Value: nums}
+	return Field{Key: key, Type: Uint16sType, Interface: nums}
 }
 
 // Uint8s constructs a field that carries a slice of unsigned integers.
 func Uint8s(key string, nums []uint8) Field {
-	return Field{Key: key, Type: Uint8sType, Value: nums}
+	return Field{Key: key, Type: Uint8sType, Interface: nums}
 }
 
 // Uintptrs constructs a field that carries a slice of pointer addresses.
 func Uintptrs(key string, us []uintptr) Field {
-	return Field{Key: key, Type: UintptrsType, Value: us}
+	return Field{Key: key, Type: UintptrsType, Interface: us}
 }
@@ -1,34 +1,191 @@
 package log
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
 
 type LogLevel string
 
 const (
-	LevelDebug LogLevel = "DEBUG"
-	LevelInfo  LogLevel = "INFO"
-	LevelWarn  LogLevel = "WARN"
-	LevelError LogLevel = "ERROR"
-	LevelFatal LogLevel = "FATAL"
+	LevelTrace  LogLevel = "TRACE"
+	LevelDebug  LogLevel = "DEBUG"
+	LevelInfo   LogLevel = "INFO"
+	LevelWarn   LogLevel = "WARN"
+	LevelError  LogLevel = "ERROR"
+	LevelDPanic LogLevel = "DPANIC"
+	LevelPanic  LogLevel = "PANIC"
+	LevelFatal  LogLevel = "FATAL"
 )
 
+// ErrUnknownLevel is returned when parsing a level name that is not recognized
+var ErrUnknownLevel = errors.New("unknown log level")
+
+// ErrZeroAtomicLevel is returned when changing an AtomicLevel that was not
+// created with NewAtomicLevel
+var ErrZeroAtomicLevel = errors.New("atomic level not created with NewAtomicLevel")
+
 func (l LogLevel) String() string {
 	return string(l)
 }
 
+// Enabled reports whether entries at the given level are logged when l is the
+// minimum enabled level
+func (l LogLevel) Enabled(level LogLevel) bool {
+	return level.rank() >= l.rank()
+}
+
+// Less reports whether l is less severe than other
+func (l LogLevel) Less(other LogLevel) bool {
+	return l.rank() < other.rank()
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (l LogLevel) MarshalText() ([]byte, error) {
+	return []byte(l), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, so LogLevel can be used
+// in JSON, YAML and other text based configurations. Unlike ParseLevel,
+// unknown names are rejected.
+func (l *LogLevel) UnmarshalText(text []byte) error {
+	level, err := ParseLevelStrict(string(text))
+	if err != nil {
+		return err
+	}
+
+	*l = level
+	return nil
+}
+
+// Set implements flag.Value
+func (l *LogLevel) Set(s string) error {
+	return l.UnmarshalText([]byte(s))
+}
+
+// rank orders levels by severity, unknown levels are ranked as info like the
+// logger does
+func (l LogLevel) rank() int {
+	switch l {
+	case LevelTrace:
+		return 0
+	case LevelDebug:
+		return 1
+	case LevelInfo:
+		return 2
+	case LevelWarn:
+		return 3
+	case LevelError:
+		return 4
+	case LevelDPanic:
+		return 5
+	case LevelPanic:
+		return 6
+	case LevelFatal:
+		return 7
+	default:
+		return 2
+	}
+}
+
+// ParseLevel parses a case insensitive level name, unknown names fall back to LevelDebug
 func ParseLevel(l string) LogLevel {
+	level, ok := parseLevel(l)
+	if !ok {
+		return LevelDebug
+	}
+
+	return level
+}
+
+// ParseLevelStrict parses a case insensitive level name, unknown names are
+// reported with ErrUnknownLevel
+func ParseLevelStrict(l string) (LogLevel, error) {
+	level, ok := parseLevel(l)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownLevel, l)
+	}
+
+	return level, nil
+}
+
+// parseLevel reports whether l is a known level name
+func parseLevel(l string) (LogLevel, bool) {
 	switch strings.ToUpper(l) {
+	case "TRACE":
+		return LevelTrace, true
 	case "DEBUG":
-		return LevelDebug
+		return LevelDebug, true
 	case "INFO":
-		return LevelInfo
+		return LevelInfo, true
 	case "WARN", "WARNING":
-		return LevelWarn
+		return LevelWarn, true
 	case "ERROR":
-		return LevelError
+		return LevelError, true
+	case "DPANIC":
+		return LevelDPanic, true
+	case "PANIC":
+		return LevelPanic, true
 	case "FATAL":
-		return LevelFatal
+		return LevelFatal, true
 	default:
-		return LevelDebug
+		return "", false
+	}
+}
+
+// AtomicLevel is a LogLevel that can be read and changed concurrently at
+// runtime. Copies share the same underlying level, so one AtomicLevel can be
+// passed to several loggers to change all of them at once.
+//
+// AtomicLevel must be created with NewAtomicLevel, the zero AtomicLevel is
+// shared by no logger, reports INFO and can't be changed.
+type AtomicLevel struct {
+	value *atomic.Value
+}
+
+// NewAtomicLevel creates an AtomicLevel set to the given level, unknown levels
+// are set as INFO like loggers treat them.
+func NewAtomicLevel(level LogLevel) AtomicLevel {
+	a := AtomicLevel{value: new(atomic.Value)}
+	if err := a.SetLevel(level); err != nil {
+		a.value.Store(LevelInfo)
+	}
+
+	return a
+}
+
+// Level returns the current level.
+func (a AtomicLevel) Level() LogLevel {
+	if a.value == nil {
+		return LevelInfo
 	}
+
+	return a.value.Load().(LogLevel)
+}
+
+// SetLevel changes the level of every logger sharing this AtomicLevel. Level
+// names are parsed by ParseLevelStrict, unknown ones leave the level unchanged.
+func (a AtomicLevel) SetLevel(level LogLevel) error {
+	parsed, err := ParseLevelStrict(string(level))
+	if err != nil {
+		return err
+	}
+
+	if a.value == nil {
+		return ErrZeroAtomicLevel
+	}
+
+	a.value.Store(parsed)
+	return nil
+}
+
+// Enabled reports whether entries at the given level are logged
+func (a AtomicLevel) Enabled(level LogLevel) bool {
+	return a.Level().Enabled(level)
+}
+
+func (a AtomicLevel) String() string {
+	return a.Level().String()
 }
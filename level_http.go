@@ -0,0 +1,156 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LevelHandler is an http.Handler that inspects and changes an AtomicLevel.
+//
+// GET responds with the current level:
+//
+//	{"level":"INFO"}
+//
+// PUT and POST change the level. The optional duration makes the change
+// temporary, after which the level reverts to the one in effect before it:
+//
+//	{"level":"debug","duration":"10m"}
+//
+// Unknown level names and malformed durations are rejected with 400, changes
+// of a zero AtomicLevel fail with 500.
+type LevelHandler struct {
+	level    AtomicLevel
+	mutex    sync.Mutex
+	timer    *time.Timer
+	revertTo LogLevel
+	expireAt time.Time
+}
+
+type levelRequest struct {
+	Level    string `json:"level"`
+	Duration string `json:"duration,omitempty"`
+}
+
+type levelResponse struct {
+	Level    LogLevel   `json:"level"`
+	ExpireAt *time.Time `json:"expire_at,omitempty"`
+}
+
+type levelErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewLevelHandler creates a LevelHandler serving the given level
+func NewLevelHandler(level AtomicLevel) *LevelHandler {
+	return &LevelHandler{level: level}
+}
+
+func (h *LevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.writeJSON(w, http.StatusOK, h.current())
+	case http.MethodPut, http.MethodPost:
+		var request levelRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			h.writeJSON(w, http.StatusBadRequest, levelErrorResponse{Error: fmt.Sprintf("invalid request body: %v", err)})
+			return
+		}
+
+		level, err := ParseLevelStrict(request.Level)
+		if err != nil {
+			h.writeJSON(w, http.StatusBadRequest, levelErrorResponse{Error: err.Error()})
+			return
+		}
+
+		var duration time.Duration
+		if request.Duration != "" {
+			var err error
+			duration, err = time.ParseDuration(request.Duration)
+			if err != nil || duration <= 0 {
+				h.writeJSON(w, http.StatusBadRequest, levelErrorResponse{Error: fmt.Sprintf("invalid duration %q", request.Duration)})
+				return
+			}
+		}
+
+		if err := h.setLevel(level, duration); err != nil {
+			h.writeJSON(w, http.StatusInternalServerError, levelErrorResponse{Error: err.Error()})
+			return
+		}
+
+		h.writeJSON(w, http.StatusOK, h.current())
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		h.writeJSON(w, http.StatusMethodNotAllowed, levelErrorResponse{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+	}
+}
+
+// setLevel changes the level, reverting it after duration if it is positive.
+// A pending revert keeps the level from before the first temporary change.
+func (h *LevelHandler) setLevel(level LogLevel, duration time.Duration) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.level.value == nil {
+		return ErrZeroAtomicLevel
+	}
+
+	pending := h.timer != nil
+	if pending {
+		h.timer.Stop()
+		h.timer = nil
+		h.expireAt = time.Time{}
+	}
+
+	if duration <= 0 {
+		return h.level.SetLevel(level)
+	}
+
+	if !pending {
+		h.revertTo = h.level.Level()
+	}
+
+	if err := h.level.SetLevel(level); err != nil {
+		return err
+	}
+	h.expireAt = time.Now().Add(duration)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(duration, func() {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+
+		// the timer was replaced by a later change
+		if h.timer != timer {
+			return
+		}
+
+		_ = h.level.SetLevel(h.revertTo)
+		h.timer = nil
+		h.expireAt = time.Time{}
+	})
+	h.timer = timer
+
+	return nil
+}
+
+func (h *LevelHandler) current() levelResponse {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	response := levelResponse{Level: h.level.Level()}
+	if h.timer != nil {
+		expireAt := h.expireAt
+		response.ExpireAt = &expireAt
+	}
+
+	return response
+}
+
+func (h *LevelHandler) writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
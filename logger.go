@@ -7,6 +7,8 @@ import (
 
 // A Logger provides fast, leveled, structured logging
 type Logger interface {
+	Trace(context.Context, string, ...Field)
+	Tracew(context.Context, string, ...interface{})
 	Debug(context.Context, string, ...Field)
 	Debugw(context.Context, string, ...interface{})
 	Info(context.Context, string, ...Field)
@@ -15,10 +17,28 @@ type Logger interface {
 	Warnw(context.Context, string, ...interface{})
 	Error(context.Context, string, ...Field)
 	Errorw(context.Context, string, ...interface{})
+	DPanic(context.Context, string, ...Field)
+	DPanicw(context.Context, string, ...interface{})
 	Panic(context.Context, string, ...Field)
 	Panicw(context.Context, string, ...interface{})
 	Fatal(context.Context, string, ...Field)
 	Fatalw(context.Context, string, ...interface{})
+	Tracef(context.Context, string, ...interface{})
+	Debugf(context.Context, string, ...interface{})
+	Infof(context.Context, string, ...interface{})
+	Warnf(context.Context, string, ...interface{})
+	Errorf(context.Context, string, ...interface{})
+	DPanicf(context.Context, string, ...interface{})
+	Panicf(context.Context, string, ...interface{})
+	Fatalf(context.Context, string, ...interface{})
+	Log(context.Context, LogLevel, string, ...Field)
+	Logw(context.Context, LogLevel, string, ...interface{})
+	Enabled(context.Context, LogLevel) bool
+	With(...Field) Logger
+	Withw(...interface{}) Logger
+	Named(string) Logger
+	Sync() error
+	Close() error
 }
 
 var (
@@ -44,50 +64,141 @@ func ReplaceGlobals(logger Logger) {
 	globalLogger = logger
 }
 
+// With creates a child of the package level logger with structured context
+func With(fields ...Field) Logger {
+	return globalLogger.With(fields...)
+}
+
+// Withw creates a child of the package level logger with loosely typed key-value pairs
+func Withw(keyAndValues ...interface{}) Logger {
+	return globalLogger.Withw(keyAndValues...)
+}
+
+// Named creates a child of the package level logger with the name segment appended
+func Named(name string) Logger {
+	return globalLogger.Named(name)
+}
+
+// Sync flushes any buffered log entries of the package level logger
+func Sync() error {
+	return globalLogger.Sync()
+}
+
+// The package level logging functions log with the logger carried by ctx or
+// the package level logger. They call it through the Logger interface, so
+// unlike the methods of ZapLogger they allocate the slice of their fields.
+
+func Trace(ctx context.Context, message string, fields ...Field) {
+	fromContext(ctx).Trace(ctx, message, fields...)
+}
+
+func Tracew(ctx context.Context, message string, keyAndValues ...interface{}) {
+	fromContext(ctx).Tracew(ctx, message, keyAndValues...)
+}
+
+func Tracef(ctx context.Context, format string, args ...interface{}) {
+	fromContext(ctx).Tracef(ctx, format, args...)
+}
+
 func Debug(ctx context.Context, message string, fields ...Field) {
-	globalLogger.Debug(ctx, message, fields...)
+	fromContext(ctx).Debug(ctx, message, fields...)
 }
 
 func Debugw(ctx context.Context, message string, keyAndValues ...interface{}) {
-	globalLogger.Debugw(ctx, message, keyAndValues...)
+	fromContext(ctx).Debugw(ctx, message, keyAndValues...)
+}
+
+func Debugf(ctx context.Context, format string, args ...interface{}) {
+	fromContext(ctx).Debugf(ctx, format, args...)
 }
 
 func Info(ctx context.Context, message string, fields ...Field) {
-	globalLogger.Info(ctx, message, fields...)
+	fromContext(ctx).Info(ctx, message, fields...)
 }
 
 func Infow(ctx context.Context, message string, keyAndValues ...interface{}) {
-	globalLogger.Infow(ctx, message, keyAndValues...)
+	fromContext(ctx).Infow(ctx, message, keyAndValues...)
+}
+
+func Infof(ctx context.Context, format string, args ...interface{}) {
+	fromContext(ctx).Infof(ctx, format, args...)
 }
 
 func Warn(ctx context.Context, message string, fields ...Field) {
-	globalLogger.Warn(ctx, message, fields...)
+	fromContext(ctx).Warn(ctx, message, fields...)
 }
 
 func Warnw(ctx context.Context, message string, keyAndValues ...interface{}) {
-	globalLogger.Warnw(ctx, message, keyAndValues...)
+	fromContext(ctx).Warnw(ctx, message, keyAndValues...)
+}
+
+func Warnf(ctx context.Context, format string, args ...interface{}) {
+	fromContext(ctx).Warnf(ctx, format, args...)
 }
 
 func Error(ctx context.Context, message string, fields ...Field) {
-	globalLogger.Error(ctx, message, fields...)
+	fromContext(ctx).Error(ctx, message, fields...)
 }
 
 func Errorw(ctx context.Context, message string, keyAndValues ...interface{}) {
-	globalLogger.Errorw(ctx, message, keyAndValues...)
+	fromContext(ctx).Errorw(ctx, message, keyAndValues...)
+}
+
+func Errorf(ctx context.Context, format string, args ...interface{}) {
+	fromContext(ctx).Errorf(ctx, format, args...)
+}
+
+// DPanic logs at DPANIC level, it panics if the logger is in development mode
+// and logs at ERROR level otherwise
+func DPanic(ctx context.Context, message string, fields ...Field) {
+	fromContext(ctx).DPanic(ctx, message, fields...)
+}
+
+// DPanicw logs at DPANIC level, it panics if the logger is in development mode
+// and logs at ERROR level otherwise
+func DPanicw(ctx context.Context, message string, keyAndValues ...interface{}) {
+	fromContext(ctx).DPanicw(ctx, message, keyAndValues...)
+}
+
+func DPanicf(ctx context.Context, format string, args ...interface{}) {
+	fromContext(ctx).DPanicf(ctx, format, args...)
 }
 
 func Panic(ctx context.Context, message string, fields ...Field) {
-	globalLogger.Panic(ctx, message, fields...)
+	fromContext(ctx).Panic(ctx, message, fields...)
 }
 
 func Panicw(ctx context.Context, message string, keyAndValues ...interface{}) {
-	globalLogger.Panicw(ctx, message, keyAndValues...)
+	fromContext(ctx).Panicw(ctx, message, keyAndValues...)
+}
+
+func Panicf(ctx context.Context, format string, args ...interface{}) {
+	fromContext(ctx).Panicf(ctx, format, args...)
 }
 
 func Fatal(ctx context.Context, message string, fields ...Field) {
-	globalLogger.Fatal(ctx, message, fields...)
+	fromContext(ctx).Fatal(ctx, message, fields...)
 }
 
 func Fatalw(ctx context.Context, message string, keyAndValues ...interface{}) {
-	globalLogger.Fatalw(ctx, message, keyAndValues...)
+	fromContext(ctx).Fatalw(ctx, message, keyAndValues...)
+}
+
+func Fatalf(ctx context.Context, format string, args ...interface{}) {
+	fromContext(ctx).Fatalf(ctx, format, args...)
+}
+
+// Log logs at the level decided at runtime
+func Log(ctx context.Context, level LogLevel, message string, fields ...Field) {
+	fromContext(ctx).Log(ctx, level, message, fields...)
+}
+
+// Logw logs at the level decided at runtime
+func Logw(ctx context.Context, level LogLevel, message string, keyAndValues ...interface{}) {
+	fromContext(ctx).Logw(ctx, level, message, keyAndValues...)
+}
+
+// Enabled reports whether the package level logger logs at the given level
+func Enabled(ctx context.Context, level LogLevel) bool {
+	return fromContext(ctx).Enabled(ctx, level)
 }
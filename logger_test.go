@@ -1,12 +1,25 @@
 package log
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"flag"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var (
@@ -74,11 +87,34 @@ func TestPanic(t *testing.T) {
 }
 
 func TestFatal(t *testing.T) {
-	Fatal(testContext, "Fatal test", testFields...)
+	if path := os.Getenv("TEST_FATAL_OUTPUT"); path != "" {
+		file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		ReplaceGlobals(New(WithWriter(file)))
+		Fatal(testContext, "Fatal test", testFields...)
+		return
+	}
+
+	file, _ := ioutil.TempFile("", "fatal*.log")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatal$")
+	cmd.Env = append(os.Environ(), "TEST_FATAL_OUTPUT="+file.Name())
+
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1 but got %v", err)
+	}
+
+	content, _ := ioutil.ReadFile(file.Name())
+	if !strings.Contains(string(content), "Fatal test") {
+		t.Errorf("fatal entry was not flushed before exit: %q", content)
+	}
 }
 
 func TestNewWriter(t *testing.T) {
 	file, _ := ioutil.TempFile(".", "logger*.log")
+	defer os.Remove(file.Name())
 	defer file.Close()
 
 	type tt struct{}
@@ -118,6 +154,7 @@ func TestSuger(t *testing.T) {
 	testContext = context.Background()
 
 	file, _ := ioutil.TempFile(".", "logger*.log")
+	defer os.Remove(file.Name())
 	defer file.Close()
 
 	type tt struct{}
@@ -144,3 +181,1097 @@ func TestSuger(t *testing.T) {
 	Warnw(ctx, "Warn test")
 	Errorw(ctx, "Error test")
 }
+
+func TestWith(t *testing.T) {
+	buffer := new(bytes.Buffer)
+
+	logger := New(
+		WithWriter(buffer),
+		WithStaticFields([]Field{String("service", "test")}),
+	)
+
+	child := logger.Named("db").With(String("table", "user")).Withw("shard", 3)
+	child.Info(testContext, "child test")
+	logger.Info(testContext, "parent test")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines but got %d", len(lines))
+	}
+
+	for _, expected := range []string{`"N":"db"`, `"service":"test"`, `"table":"user"`, `"shard":3`} {
+		if !strings.Contains(lines[0], expected) {
+			t.Errorf("expected %s in %s", expected, lines[0])
+		}
+	}
+
+	for _, unexpected := range []string{`"N":"db"`, `"table":"user"`, `"shard":3`} {
+		if strings.Contains(lines[1], unexpected) {
+			t.Errorf("unexpected %s in %s", unexpected, lines[1])
+		}
+	}
+}
+
+func TestCaller(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer))
+	child := logger.Named("db").With(String("table", "user"))
+
+	logger.Info(testContext, "direct")
+	child.Warnw(testContext, "child", "shard", 3)
+	logger.Log(testContext, LevelError, "log")
+	Info(IntoContext(testContext, child), "context")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines but got %d", len(lines))
+	}
+
+	caller := regexp.MustCompile(`"C":"[^/"]+/logger_test.go:\d+"`)
+	for _, line := range lines {
+		if !caller.MatchString(line) {
+			t.Errorf("expected the caller in logger_test.go in %s", line)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	file, _ := ioutil.TempFile("", "close*.log")
+	defer os.Remove(file.Name())
+
+	logger := New(WithWriter(file))
+	logger.Info(testContext, "Close test")
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("close logger failed due to %v", err)
+	}
+
+	if _, err := file.Write([]byte("after close")); err == nil {
+		t.Error("expected writer to be closed")
+	}
+
+	content, _ := ioutil.ReadFile(file.Name())
+	if !strings.Contains(string(content), "Close test") {
+		t.Errorf("expected entry in %q", content)
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	console := new(bytes.Buffer)
+	file, _ := ioutil.TempFile("", "outputs*.log")
+	defer os.Remove(file.Name())
+
+	logger := New(WithOutputs(
+		Output{Encoder: ColorConsole, Writer: console, LogLevel: LevelDebug},
+		Output{Encoder: JSON, Writer: file, LogLevel: LevelInfo},
+	))
+
+	logger.Debug(testContext, "debug entry")
+	logger.Info(testContext, "info entry", String("key", "value"))
+
+	if !logger.Enabled(testContext, LevelDebug) {
+		t.Error("expected debug to be enabled by the console output")
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("close logger failed due to %v", err)
+	}
+
+	if _, err := file.Write([]byte("after close")); err == nil {
+		t.Error("expected file output to be closed")
+	}
+
+	output := console.String()
+	if !strings.Contains(output, "debug entry") || !strings.Contains(output, "info entry") {
+		t.Errorf("expected both entries in console output %q", output)
+	}
+	if !strings.Contains(output, "\x1b[") {
+		t.Errorf("expected colored levels in console output %q", output)
+	}
+
+	content, _ := ioutil.ReadFile(file.Name())
+	if strings.Contains(string(content), "debug entry") {
+		t.Errorf("expected no debug entry in file output %q", content)
+	}
+	if !strings.Contains(string(content), `"M":"info entry","key":"value"`) {
+		t.Errorf("expected json info entry in file output %q", content)
+	}
+}
+
+func TestLevelRouting(t *testing.T) {
+	high := new(bytes.Buffer)
+	low := new(bytes.Buffer)
+	level := NewAtomicLevel(LevelDebug)
+
+	logger := New(WithLevelRouting(LevelWarn, high, low), WithAtomicLevel(level))
+	logger.Trace(testContext, "trace entry")
+	logger.Debug(testContext, "debug entry")
+	logger.Info(testContext, "info entry")
+	logger.Warn(testContext, "warn entry")
+	logger.Error(testContext, "error entry")
+
+	level.SetLevel(LevelError)
+	logger.Warn(testContext, "hidden warn entry")
+
+	if output := high.String(); strings.Contains(output, "debug entry") || strings.Contains(output, "info entry") ||
+		!strings.Contains(output, `"M":"warn entry"`) || !strings.Contains(output, "error entry") {
+		t.Errorf("expected warn and error entries in high output %q", output)
+	}
+
+	if output := low.String(); strings.Contains(output, "trace entry") || strings.Contains(output, "warn entry") ||
+		strings.Contains(output, "error entry") || !strings.Contains(output, "debug entry") || !strings.Contains(output, "info entry") {
+		t.Errorf("expected debug and info entries in low output %q", output)
+	}
+
+	if strings.Contains(high.String(), "hidden") {
+		t.Errorf("expected logger level to apply to routed outputs")
+	}
+}
+
+func TestSharedWriter(t *testing.T) {
+	file, _ := ioutil.TempFile("", "shared*.log")
+	defer os.Remove(file.Name())
+
+	// both outputs write to the file through one AsyncWriter, which keeps the
+	// entries in order and closes the file once
+	logger := New(WithLevelRouting(LevelWarn, file, file), WithAsyncWriter(AsyncWriterConfig{}))
+	for index := 0; index < 100; index++ {
+		logger.Info(testContext, "info entry", Int("index", 2*index))
+		logger.Warn(testContext, "warn entry", Int("index", 2*index+1))
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("close logger failed due to %v", err)
+	}
+
+	content, _ := ioutil.ReadFile(file.Name())
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 200 {
+		t.Fatalf("expected 200 lines but got %d", len(lines))
+	}
+
+	for index, line := range lines {
+		if !strings.HasSuffix(line, `"index":`+strconv.Itoa(index)+"}") {
+			t.Fatalf("expected entry %d in order but got %s", index, line)
+		}
+	}
+
+	file, _ = os.OpenFile(file.Name(), os.O_WRONLY|os.O_APPEND, 0)
+	logger = New(WithOutputs(Output{Writer: file}, Output{Writer: file, Encoder: Logfmt}))
+	if err := logger.Close(); err != nil {
+		t.Errorf("expected the shared file to be closed once but got %v", err)
+	}
+}
+
+func TestAtomicLevel(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	level := NewAtomicLevel(LevelInfo)
+
+	first := New(WithWriter(buffer), WithAtomicLevel(level))
+	second := New(WithWriter(buffer), WithAtomicLevel(level), WithLogLevel(LevelFatal))
+
+	first.Debug(testContext, "first hidden")
+	second.Debug(testContext, "second hidden")
+
+	level.SetLevel(LevelDebug)
+	first.Debug(testContext, "first shown")
+	second.Debug(testContext, "second shown")
+
+	if level.Level() != LevelDebug {
+		t.Errorf("expected level %s but got %s", LevelDebug, level.Level())
+	}
+
+	output := buffer.String()
+	if strings.Contains(output, "hidden") {
+		t.Errorf("unexpected debug entry in %s", output)
+	}
+
+	if !strings.Contains(output, "first shown") || !strings.Contains(output, "second shown") {
+		t.Errorf("expected debug entries in %s", output)
+	}
+
+	if err := level.SetLevel("verbose"); !errors.Is(err, ErrUnknownLevel) || level.Level() != LevelDebug {
+		t.Errorf("expected unknown level to be rejected but got %v and %s", err, level.Level())
+	}
+
+	if err := level.SetLevel("warning"); err != nil || level.Level() != LevelWarn {
+		t.Errorf("expected warning to set %s but got %v and %s", LevelWarn, err, level.Level())
+	}
+
+	var zero AtomicLevel
+	if err := zero.SetLevel(LevelDebug); !errors.Is(err, ErrZeroAtomicLevel) || zero.Level() != LevelInfo {
+		t.Errorf("expected zero level to report %s and reject changes but got %v", LevelInfo, err)
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	level := NewAtomicLevel(LevelInfo)
+	server := httptest.NewServer(NewLevelHandler(level))
+	defer server.Close()
+
+	send := func(method, body string) (int, string) {
+		request, _ := http.NewRequest(method, server.URL, strings.NewReader(body))
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("request level handler failed due to %v", err)
+		}
+		defer response.Body.Close()
+
+		content, _ := ioutil.ReadAll(response.Body)
+		return response.StatusCode, string(content)
+	}
+
+	if status, body := send(http.MethodGet, ""); status != http.StatusOK || !strings.Contains(body, `"level":"INFO"`) {
+		t.Errorf("unexpected get response %d %s", status, body)
+	}
+
+	if status, _ := send(http.MethodPut, `{"level":"verbose"}`); status != http.StatusBadRequest {
+		t.Errorf("expected unknown level to be rejected but got %d", status)
+	}
+
+	if status, _ := send(http.MethodDelete, ""); status != http.StatusMethodNotAllowed {
+		t.Errorf("expected method not allowed but got %d", status)
+	}
+
+	if status, body := send(http.MethodPut, `{"level":"warn"}`); status != http.StatusOK || level.Level() != LevelWarn {
+		t.Errorf("unexpected put response %d %s", status, body)
+	}
+
+	if status, body := send(http.MethodPost, `{"level":"debug","duration":"50ms"}`); status != http.StatusOK || !strings.Contains(body, "expire_at") {
+		t.Errorf("unexpected post response %d %s", status, body)
+	}
+
+	if level.Level() != LevelDebug {
+		t.Errorf("expected level %s but got %s", LevelDebug, level.Level())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for level.Level() != LevelWarn && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if level.Level() != LevelWarn {
+		t.Errorf("expected level to revert to %s but got %s", LevelWarn, level.Level())
+	}
+
+	// a zero level is served but can't be changed
+	zero := httptest.NewServer(NewLevelHandler(AtomicLevel{}))
+	defer zero.Close()
+
+	response, err := http.Get(zero.URL)
+	if err != nil {
+		t.Fatalf("request level handler failed due to %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("expected zero level to be served but got %d", response.StatusCode)
+	}
+
+	response, err = http.Post(zero.URL, "application/json", strings.NewReader(`{"level":"debug"}`))
+	if err != nil {
+		t.Fatalf("request level handler failed due to %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected zero level change to fail but got %d", response.StatusCode)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if level := ParseLevel("typo"); level != LevelDebug {
+		t.Errorf("expected %s but got %s", LevelDebug, level)
+	}
+
+	if level, err := ParseLevelStrict("warning"); err != nil || level != LevelWarn {
+		t.Errorf("expected %s but got %s, %v", LevelWarn, level, err)
+	}
+
+	if _, err := ParseLevelStrict("typo"); !errors.Is(err, ErrUnknownLevel) {
+		t.Errorf("expected ErrUnknownLevel but got %v", err)
+	}
+
+	var config struct {
+		Level LogLevel `json:"level"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"level":"error"}`), &config); err != nil || config.Level != LevelError {
+		t.Errorf("expected %s but got %s, %v", LevelError, config.Level, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"level":"typo"}`), &config); !errors.Is(err, ErrUnknownLevel) {
+		t.Errorf("expected ErrUnknownLevel but got %v", err)
+	}
+
+	if content, err := json.Marshal(config); err != nil || string(content) != `{"level":"ERROR"}` {
+		t.Errorf("unexpected json %s, %v", content, err)
+	}
+
+	level := LevelInfo
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Var(&level, "level", "log level")
+	if err := flags.Parse([]string{"-level", "fatal"}); err != nil || level != LevelFatal {
+		t.Errorf("expected %s but got %s, %v", LevelFatal, level, err)
+	}
+}
+
+func TestLevelOrder(t *testing.T) {
+	if !LevelDebug.Less(LevelInfo) || LevelError.Less(LevelWarn) || LevelWarn.Less(LevelWarn) {
+		t.Error("unexpected level order")
+	}
+
+	if !LevelWarn.Enabled(LevelError) || !LevelWarn.Enabled(LevelWarn) || LevelWarn.Enabled(LevelInfo) {
+		t.Error("unexpected enabled levels")
+	}
+}
+
+func TestTrace(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	level := NewAtomicLevel(LevelDebug)
+	logger := New(WithWriter(buffer), WithAtomicLevel(level))
+
+	logger.Trace(testContext, "hidden trace")
+	level.SetLevel(LevelTrace)
+	logger.Trace(testContext, "Trace test", String("frame", "0x01"))
+	logger.Tracew(testContext, "Tracew test", "frame", "0x02")
+
+	output := buffer.String()
+	if strings.Contains(output, "hidden trace") {
+		t.Errorf("unexpected trace entry in %s", output)
+	}
+
+	for _, expected := range []string{`"L":"TRACE"`, `"frame":"0x01"`, `"frame":"0x02"`} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %s in %s", expected, output)
+		}
+	}
+}
+
+func TestDPanic(t *testing.T) {
+	buffer := new(bytes.Buffer)
+
+	logger := New(WithWriter(buffer))
+	logger.DPanic(testContext, "DPanic test")
+	logger.Log(testContext, LevelDPanic, "Log test")
+	if strings.Count(buffer.String(), `"L":"ERROR"`) != 2 {
+		t.Errorf("expected dpanic entries at error level in %s", buffer.String())
+	}
+
+	// the threshold still applies to the DPANIC level
+	buffer.Reset()
+	New(WithWriter(buffer), WithLogLevel(LevelDPanic)).DPanicw(testContext, "DPanicw test")
+	if !strings.Contains(buffer.String(), "DPanicw test") {
+		t.Errorf("expected dpanic entry in %s", buffer.String())
+	}
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("expected development logger to panic")
+		}
+	}()
+
+	New(WithWriter(buffer), WithDevelopment(true)).DPanic(testContext, "DPanic test")
+}
+
+func TestLog(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer), WithLogLevel(LevelInfo))
+
+	if logger.Enabled(testContext, LevelDebug) || !logger.Enabled(testContext, LevelWarn) {
+		t.Error("unexpected enabled levels")
+	}
+
+	logger.Log(testContext, LevelDebug, "hidden log")
+	logger.Log(testContext, LevelWarn, "Log test", Int("status", 404))
+	logger.Logw(testContext, LevelError, "Logw test", "status", 500)
+
+	output := buffer.String()
+	if strings.Contains(output, "hidden log") {
+		t.Errorf("unexpected debug entry in %s", output)
+	}
+
+	for _, expected := range []string{`"L":"WARN"`, `"status":404`, `"L":"ERROR"`, `"status":500`} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %s in %s", expected, output)
+		}
+	}
+}
+
+type countingStringer struct {
+	count *int
+}
+
+func (s countingStringer) String() string {
+	*s.count++
+	return "formatted"
+}
+
+func TestPrintf(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer), WithLogLevel(LevelInfo))
+
+	count := 0
+	logger.Debugf(testContext, "hidden %s", countingStringer{&count})
+	logger.Infof(testContext, "Infof test %d %s", 42, countingStringer{&count})
+
+	if count != 1 {
+		t.Errorf("expected 1 formatting but got %d", count)
+	}
+
+	if output := buffer.String(); strings.Contains(output, "hidden") || !strings.Contains(output, `"M":"Infof test 42 formatted"`) {
+		t.Errorf("unexpected output %s", output)
+	}
+
+	defer func() {
+		if err := recover(); err != "Panicf test 1" {
+			t.Errorf("unexpected panic %v", err)
+		}
+	}()
+
+	logger.Panicf(testContext, "Panicf test %d", 1)
+}
+
+func TestContextLogger(t *testing.T) {
+	if FromContext(testContext) != globalLogger {
+		t.Error("expected package level logger without a context logger")
+	}
+
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer)).With(String("request_id", "665544332211"))
+	ctx := IntoContext(testContext, logger)
+
+	if FromContext(ctx) != logger {
+		t.Error("expected logger from context")
+	}
+
+	Info(ctx, "Context test")
+	Infof(ctx, "Context %s", "test")
+
+	output := buffer.String()
+	if strings.Count(output, `"request_id":"665544332211"`) != 2 {
+		t.Errorf("expected context logger to be used in %s", output)
+	}
+}
+
+func TestContextFields(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(
+		WithWriter(buffer),
+		WithDynamicFields(func(ctx context.Context) []Field {
+			return []Field{String("source", "dynamic"), String("region", "eu")}
+		}),
+	)
+
+	ctx := ContextWithFields(testContext, String("request_id", "1"), String("source", "context"))
+	ctx = ContextWithKeyAndValues(ctx, "request_id", "2", "user", "alice")
+
+	logger.Info(ctx, "Context fields test", String("user", "bob"))
+	logger.Infow(ctx, "Context key and values test", "user", "carol")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines but got %d", len(lines))
+	}
+
+	for _, expected := range []string{`"user":"bob"`, `"request_id":"2"`, `"source":"context"`, `"region":"eu"`} {
+		if strings.Count(lines[0], expected[:strings.Index(expected, ":")]) != 1 || !strings.Contains(lines[0], expected) {
+			t.Errorf("expected exactly %s in %s", expected, lines[0])
+		}
+	}
+
+	for _, expected := range []string{`"user":"carol"`, `"request_id":"2"`, `"source":"context"`} {
+		if strings.Count(lines[1], expected[:strings.Index(expected, ":")]) != 1 || !strings.Contains(lines[1], expected) {
+			t.Errorf("expected exactly %s in %s", expected, lines[1])
+		}
+	}
+}
+
+type testUser struct {
+	Name  string
+	Roles []string
+}
+
+func (u testUser) MarshalLogObject(enc ObjectEncoder) error {
+	enc.AddString("name", u.Name)
+	return enc.AddArray("roles", ArrayMarshalerFunc(func(enc ArrayEncoder) error {
+		for _, role := range u.Roles {
+			enc.AppendString(role)
+		}
+		return nil
+	}))
+}
+
+func TestObjectAndArray(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer))
+
+	alice := testUser{Name: "alice", Roles: []string{"admin"}}
+	bob := testUser{Name: "bob"}
+
+	logger.Info(testContext, "Object test",
+		Object("user", alice),
+		Objects("users", []testUser{alice, bob}),
+		Any("owner", bob),
+	)
+
+	for _, expected := range []string{
+		`"user":{"name":"alice","roles":["admin"]}`,
+		`"users":[{"name":"alice","roles":["admin"]},{"name":"bob","roles":[]}]`,
+		`"owner":{"name":"bob","roles":[]}`,
+	} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Errorf("expected %s in %s", expected, buffer.String())
+		}
+	}
+}
+
+func TestDict(t *testing.T) {
+	for _, c := range []struct {
+		encoder  Encoder
+		expected []string
+	}{
+		{JSON, []string{
+			`"component":{"name":"api"}`,
+			`"http":{"method":"GET","status":200,"route":{"path":"/users"}}`,
+			`"user":{"id":42}`,
+			`"took":"1s"`,
+		}},
+		{Console, []string{
+			`"component.name": "api"`,
+			`"http.method": "GET", "http.status": 200, "http.route.path": "/users"`,
+			`"user.id": 42`,
+			`"took": "1s"`,
+		}},
+		{Logfmt, []string{
+			`component.name=api`,
+			`http.method=GET http.status=200 http.route.path=/users`,
+			`user.id=42`,
+			`took=1s`,
+		}},
+	} {
+		buffer := new(bytes.Buffer)
+		logger := New(WithWriter(buffer), WithEncoder(c.encoder)).With(Dict("component", String("name", "api")))
+
+		logger.Info(testContext, "Dict test",
+			Dict("http", String("method", "GET"), Int("status", 200), Dict("route", String("path", "/users"))),
+			Dict("user", Int("id", 42)),
+			Duration("took", time.Second),
+		)
+
+		for _, expected := range c.expected {
+			if !strings.Contains(buffer.String(), expected) {
+				t.Errorf("expected %s in %s output %s", expected, c.encoder, buffer.String())
+			}
+		}
+	}
+}
+
+func TestLogfmt(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer), WithEncoder(Logfmt)).Named("worker").With(String("service", "api"))
+
+	Info(IntoContext(testContext, logger), "logfmt test",
+		String("quoted", `say "hi"`),
+		String("spaces", "a b"),
+		String("newline", "a\nb"),
+		String("empty", ""),
+		String("bad key", "x"),
+		Strings("tags", []string{"a", "b"}),
+		Float64("ratio", 0.5),
+		Bool("ok", true),
+		Any("reflected", map[string]interface{}{"nested": []int{1, 2}}),
+		Err(errors.New("boom")),
+	)
+
+	expected := regexp.MustCompile(`^ts=\S+ level=info logger=worker caller=\S+/logger_test.go:\d+ msg="logfmt test" ` +
+		`service=api quoted="say \\"hi\\"" spaces="a b" newline="a\\nb" empty="" bad_key=x tags.0=a tags.1=b ` +
+		`ratio=0.5 ok=true reflected.nested.0=1 reflected.nested.1=2 error=boom errorType=\*errors.errorString\n$`)
+	if output := buffer.String(); !expected.MatchString(output) {
+		t.Errorf("unexpected logfmt output %q", output)
+	}
+}
+
+func TestEncoderConfig(t *testing.T) {
+	custom := ProductionEncoderConfig()
+	custom.TimeKey = "@t"
+	custom.MessageKey = "message"
+	custom.TimeLayout = "2006/01/02"
+	custom.DurationFormat = DurationMillis
+	custom.FullCaller = true
+
+	epoch := DevelopmentEncoderConfig()
+	epoch.TimeFormat = TimeEpochMillis
+	epoch.LevelCase = LevelLowercase
+	epoch.CallerKey = ""
+
+	for _, c := range []struct {
+		name     string
+		encoder  Encoder
+		config   EncoderConfig
+		expected *regexp.Regexp
+	}{
+		{"production", JSON, ProductionEncoderConfig(),
+			regexp.MustCompile(`^\{"level":"trace","ts":"\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d+Z","caller":"[^/"]+/logger_test.go:\d+","msg":"config test","took":1.5\}\n$`)},
+		{"custom", JSON, custom,
+			regexp.MustCompile(`^\{"level":"trace","@t":"\d{4}/\d{2}/\d{2}","caller":"/\S+/logger_test.go:\d+","message":"config test","took":1500\}\n$`)},
+		{"epoch", JSON, epoch,
+			regexp.MustCompile(`^\{"L":"trace","T":\d+\.?\d*,"M":"config test","took":"1.5s"\}\n$`)},
+		{"logfmt", Logfmt, custom,
+			regexp.MustCompile(`^@t=\d{4}/\d{2}/\d{2} level=trace caller=/\S+/logger_test.go:\d+ message="config test" took=1500\n$`)},
+	} {
+		buffer := new(bytes.Buffer)
+		logger := New(WithWriter(buffer), WithEncoder(c.encoder), WithEncoderConfig(c.config), WithLogLevel(LevelTrace))
+
+		Trace(IntoContext(testContext, logger), "config test", Duration("took", 1500*time.Millisecond))
+
+		if output := buffer.String(); !c.expected.MatchString(output) {
+			t.Errorf("unexpected %s output %q", c.name, output)
+		}
+	}
+}
+
+func TestECS(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer), WithEncoder(ECS)).Named("worker").With(String("service", "api"))
+
+	ctx := ContextWithTrace(testContext, TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"})
+	logger.Error(ctx, "ecs test", Err(errors.New("boom")), Dict("http", Int("status", 500)))
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatalf("unmarshal %q failed due to %v", buffer.String(), err)
+	}
+
+	origin, _ := document["log.origin"].(map[string]interface{})
+	file, _ := origin["file"].(map[string]interface{})
+	if name, _ := file["name"].(string); !strings.HasSuffix(name, "/logger_test.go") || file["line"] == nil {
+		t.Errorf("expected log.origin.file of the test in %s", buffer.String())
+	}
+	if function, _ := origin["function"].(string); !strings.HasSuffix(function, ".TestECS") {
+		t.Errorf("expected log.origin.function of the test in %s", buffer.String())
+	}
+
+	for key, value := range map[string]string{"log.level": "error", "message": "ecs test", "log.logger": "worker", "ecs.version": ecsVersion} {
+		if document[key] != value {
+			t.Errorf("expected %s %s in %s", key, value, buffer.String())
+		}
+	}
+
+	if _, err := time.Parse(time.RFC3339Nano, fmt.Sprint(document["@timestamp"])); err != nil {
+		t.Errorf("expected @timestamp in %s", buffer.String())
+	}
+
+	expected := `"error":{"message":"boom","type":"*errors.errorString"},"trace":{"id":"4bf92f3577b34da6a3ce929d0e0e4736"},"span":{"id":"00f067aa0ba902b7"}`
+	if !strings.Contains(buffer.String(), expected) {
+		t.Errorf("expected %s in %s", expected, buffer.String())
+	}
+
+	expected = `"fields":{"service":"api","http":{"status":500}}}`
+	if !strings.HasSuffix(strings.TrimSpace(buffer.String()), expected) {
+		t.Errorf("expected %s in %s", expected, buffer.String())
+	}
+}
+
+func TestECSError(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer), WithEncoder(ECS))
+
+	err := fmt.Errorf("load user: %w", testFieldsError{errors.New("timeout")})
+	logger.Error(testContext, "ecs error test", Err(err))
+
+	for _, expected := range []string{
+		`"error":{"message":"load user: query failed: timeout","type":"*fmt.wrapError","stack_trace":"main.go:10\ndb.go:42",` +
+			`"causes":[{"message":"query failed: timeout","type":"log.testFieldsError"},{"message":"timeout","type":"*errors.errorString"}]}`,
+		`"fields":{"table":"user","attempt":3}}`,
+	} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Errorf("expected %s in %s", expected, buffer.String())
+		}
+	}
+
+	// no fields namespace without fields
+	buffer.Reset()
+	logger.Info(testContext, "ecs without fields")
+	if strings.Contains(buffer.String(), `"fields"`) || !json.Valid(buffer.Bytes()) {
+		t.Errorf("unexpected document %s", buffer.String())
+	}
+}
+
+func TestGCP(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	config := GCPEncoderConfig()
+	config.ProjectID = "my-project"
+	logger := New(WithWriter(buffer), WithEncoder(GCP), WithEncoderConfig(config))
+
+	// fields with the keys of the entry stay under the namespace
+	ctx := ContextWithTrace(testContext, TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true})
+	logger.With(String("severity", "low")).Warn(ctx, "gcp test", Int("attempt", 2), String("message", "user message"))
+	logger.Trace(testContext, "trace hidden by level")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("unmarshal %q failed due to %v", buffer.String(), err)
+	}
+
+	expected := map[string]interface{}{
+		"severity":                             "WARNING",
+		"message":                              "gcp test",
+		"logging.googleapis.com/trace":         "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+		"logging.googleapis.com/spanId":        "00f067aa0ba902b7",
+		"logging.googleapis.com/trace_sampled": true,
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("expected %s %v in %s", key, value, buffer.String())
+		}
+	}
+
+	fields, _ := entry["fields"].(map[string]interface{})
+	for key, value := range map[string]interface{}{"severity": "low", "attempt": float64(2), "message": "user message"} {
+		if fields[key] != value {
+			t.Errorf("expected fields.%s %v in %s", key, value, buffer.String())
+		}
+	}
+
+	location, _ := entry["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+	if file, _ := location["file"].(string); !strings.HasSuffix(file, "/logger_test.go") || location["line"] == "" {
+		t.Errorf("expected sourceLocation file of the test in %s", buffer.String())
+	}
+	if function, _ := location["function"].(string); !strings.HasSuffix(function, ".TestGCP") {
+		t.Errorf("expected sourceLocation function of the test in %s", buffer.String())
+	}
+
+	if _, err := time.Parse(time.RFC3339Nano, fmt.Sprint(entry["timestamp"])); err != nil {
+		t.Errorf("expected timestamp in %s", buffer.String())
+	}
+
+	for level, severity := range map[LogLevel]string{LevelTrace: "DEBUG", LevelInfo: "INFO", LevelError: "ERROR", LevelDPanic: "ERROR"} {
+		buffer.Reset()
+		New(WithWriter(buffer), WithEncoder(GCP), WithLogLevel(LevelTrace)).Log(testContext, level, "severity test")
+		if !strings.Contains(buffer.String(), `"severity":"`+severity+`"`) {
+			t.Errorf("expected severity %s for %s in %s", severity, level, buffer.String())
+		}
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	trace := TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true}
+
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer))
+	logger.Info(ContextWithTrace(testContext, trace), "traced")
+	logger.Info(testContext, "untraced")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if expected := `"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"00f067aa0ba902b7","traceSampled":true`; !strings.Contains(lines[0], expected) {
+		t.Errorf("expected %s in %s", expected, lines[0])
+	}
+	if strings.Contains(lines[1], "traceId") {
+		t.Errorf("expected no trace in %s", lines[1])
+	}
+
+	type spanKey struct{}
+	buffer.Reset()
+	logger = New(WithWriter(buffer), WithTraceExtractor(func(ctx context.Context) (TraceContext, bool) {
+		trace, ok := ctx.Value(spanKey{}).(TraceContext)
+		return trace, ok
+	}))
+	logger.Info(context.WithValue(testContext, spanKey{}, trace), "extracted")
+
+	if !strings.Contains(buffer.String(), `"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"`) {
+		t.Errorf("expected extracted trace in %s", buffer.String())
+	}
+}
+
+func TestOTel(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer), WithEncoder(OTel), WithStaticFields([]Field{String("service.name", "api")})).Named("worker")
+
+	ctx := ContextWithTrace(IntoContext(testContext, logger), TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true})
+	Warn(ctx, "otel test", Int("attempt", 2))
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal %q failed due to %v", buffer.String(), err)
+	}
+
+	expected := map[string]interface{}{
+		"SeverityText":   "WARN",
+		"SeverityNumber": float64(13),
+		"Body":           "otel test",
+		"TraceId":        "4bf92f3577b34da6a3ce929d0e0e4736",
+		"SpanId":         "00f067aa0ba902b7",
+		"TraceFlags":     float64(1),
+	}
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("expected %s %v in %s", key, value, buffer.String())
+		}
+	}
+
+	for _, key := range []string{"Timestamp", "ObservedTimestamp"} {
+		if value, _ := record[key].(string); value == "" || strings.Trim(value, "0123456789") != "" {
+			t.Errorf("expected %s as a decimal string in %s", key, buffer.String())
+		}
+	}
+
+	if !strings.Contains(buffer.String(), `"InstrumentationScope":{"Name":"worker"},"Resource":{"service.name":"api"}`) {
+		t.Errorf("expected scope and resource in %s", buffer.String())
+	}
+
+	attributes, _ := record["Attributes"].(map[string]interface{})
+	if attributes["attempt"] != float64(2) || attributes["service.name"] != nil || attributes["code.lineno"] == nil {
+		t.Errorf("expected attempt and caller in attributes of %s", buffer.String())
+	}
+
+	// nanoseconds beyond the precision of JSON numbers and caller attributes
+	// outside of a namespace
+	entry := zapcore.Entry{
+		Time:    time.Date(2022, 9, 1, 10, 0, 0, 123, time.UTC),
+		Message: "namespace",
+		Caller:  zapcore.NewEntryCaller(0, "main.go", 7, true),
+	}
+	encoded, err := newOTelEncoder(ProductionEncoderConfig(), nil).EncodeEntry(entry, []zapcore.Field{zap.Namespace("http"), zap.Int("status", 200)})
+	if err != nil {
+		t.Fatalf("encode failed due to %v", err)
+	}
+	for _, expected := range []string{`"Timestamp":"1662026400000000123"`, `"Attributes":{"code.filepath":"main.go","code.lineno":7,"code.function":"","http":{"status":200}}`} {
+		if !strings.Contains(encoded.String(), expected) {
+			t.Errorf("expected %s in %s", expected, encoded.String())
+		}
+	}
+
+	buffer.Reset()
+	logger.Info(ContextWithTrace(testContext, TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736"}), "no span")
+	if !strings.Contains(buffer.String(), `"TraceId":"4bf92f3577b34da6a3ce929d0e0e4736"`) ||
+		strings.Contains(buffer.String(), "SpanId") || strings.Contains(buffer.String(), "TraceFlags") {
+		t.Errorf("expected the trace without span in %s", buffer.String())
+	}
+}
+
+type testJoinError []error
+
+func (e testJoinError) Error() string {
+	return "joined"
+}
+
+func (e testJoinError) Unwrap() []error {
+	return e
+}
+
+type testStack []string
+
+func (s testStack) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, strings.Join(s, "\n"))
+}
+
+type testFieldsError struct {
+	err error
+}
+
+func (e testFieldsError) Error() string {
+	return "query failed: " + e.err.Error()
+}
+
+func (e testFieldsError) Unwrap() error {
+	return e.err
+}
+
+func (e testFieldsError) LogFields() []Field {
+	return []Field{String("table", "user"), Int("attempt", 3)}
+}
+
+func (e testFieldsError) StackTrace() testStack {
+	return testStack{"main.go:10", "db.go:42"}
+}
+
+type testStackError struct {
+	err   error
+	stack testStack
+}
+
+func (e testStackError) Error() string {
+	return e.err.Error()
+}
+
+func (e testStackError) Unwrap() error {
+	return e.err
+}
+
+func (e testStackError) StackTrace() testStack {
+	return e.stack
+}
+
+func TestErrorChain(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer))
+
+	timeout := errors.New("timeout")
+	err := fmt.Errorf("load user: %w", testJoinError{testFieldsError{timeout}, os.ErrNotExist})
+
+	logger.Error(testContext, "Error chain test", Err(err))
+
+	for _, expected := range []string{
+		`"error":"load user: joined"`,
+		`"errorType":"*fmt.wrapError"`,
+		`"errorCauses":[{"error":"joined","type":"log.testJoinError"},{"error":"query failed: timeout","type":"log.testFieldsError"},{"error":"timeout","type":"*errors.errorString"},{"error":"file does not exist","type":"*errors.errorString"}]`,
+		`"errorStack":"main.go:10\ndb.go:42"`,
+		`"errorFields":{"table":"user","attempt":3}`,
+	} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Errorf("expected %s in %s", expected, buffer.String())
+		}
+	}
+
+	// the deepest stack of a tree is logged, wherever it is in the walk
+	buffer.Reset()
+	deep := testStackError{err: timeout, stack: testStack{"deep.go:3"}}
+	shallow := testStackError{err: fmt.Errorf("retry: %w", deep), stack: testStack{"shallow.go:1"}}
+	logger.Error(testContext, "Error tree test", Err(testJoinError{shallow, testFieldsError{timeout}}))
+
+	if !strings.Contains(buffer.String(), `"errorStack":"deep.go:3"`) {
+		t.Errorf("expected the deepest stack in %s", buffer.String())
+	}
+}
+
+func newBenchmarkLogger(level LogLevel) *ZapLogger {
+	return NewZapLogger(&Parameter{
+		Encoder:  JSON,
+		Writer:   ioutil.Discard,
+		LogLevel: level,
+	})
+}
+
+func logScalarFields(logger *ZapLogger) {
+	logger.Info(testContext, "scalar fields",
+		Int("int", 1),
+		Int64("int64", 64),
+		Uint("uint", 4),
+		Float64("float64", 64.64),
+		Bool("bool", true),
+		String("string", "abc"),
+		Time("time", time.Unix(1600000000, 0)),
+	)
+}
+
+// TestScalarFieldsAllocs checks that scalar fields add no allocations to an
+// entry, the remaining ones are made by zap when capturing the caller. The
+// package level functions call the Logger interface, which makes the variadic
+// fields escape, so they add exactly one allocation for the slice of fields.
+func TestScalarFieldsAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not stable under the race detector")
+	}
+
+	logger := newBenchmarkLogger(LevelDebug)
+
+	withoutFields := testing.AllocsPerRun(100, func() { logger.Info(testContext, "no fields") })
+	withFields := testing.AllocsPerRun(100, func() { logScalarFields(logger) })
+
+	if withFields != withoutFields {
+		t.Errorf("expected scalar fields to add no allocations but got %v more", withFields-withoutFields)
+	}
+
+	ctx := IntoContext(testContext, logger)
+	withoutFields = testing.AllocsPerRun(100, func() { Info(ctx, "no fields") })
+	withFields = testing.AllocsPerRun(100, func() {
+		Info(ctx, "scalar fields", Int("int", 1), String("string", "abc"), Bool("bool", true))
+	})
+
+	if withFields != withoutFields+1 {
+		t.Errorf("expected scalar fields to add one allocation to package level functions but got %v", withFields-withoutFields)
+	}
+}
+
+func BenchmarkScalarFields(b *testing.B) {
+	logger := newBenchmarkLogger(LevelDebug)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logScalarFields(logger)
+		}
+	})
+}
+
+func BenchmarkNoFields(b *testing.B) {
+	logger := newBenchmarkLogger(LevelDebug)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logger.Info(testContext, "no fields")
+		}
+	})
+}
+
+func BenchmarkScalarFieldsDisabled(b *testing.B) {
+	logger := newBenchmarkLogger(LevelWarn)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logScalarFields(logger)
+		}
+	})
+}
+
+// BenchmarkScalarFieldsGlobal allocates one more than BenchmarkScalarFields
+// for the fields escaping through the Logger interface
+func BenchmarkScalarFieldsGlobal(b *testing.B) {
+	logger := newBenchmarkLogger(LevelDebug)
+	ctx := IntoContext(testContext, logger)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			Info(ctx, "scalar fields", Int("int", 1), String("string", "abc"), Bool("bool", true))
+		}
+	})
+}
+
+func TestSugarWithoutDynamicKeyAndValues(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer), WithDynamicFields(func(ctx context.Context) []Field {
+		return []Field{String("source", "dynamic")}
+	}))
+
+	logger.Infow(testContext, "Infow test", "user", "alice", 42, "answer", "dangling")
+	logger.Withw("shard", 3).Warnw(testContext, "Withw test")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines but got %d", len(lines))
+	}
+
+	for _, expected := range []string{
+		`"user":"alice"`,
+		`"invalid_kv":[{"position":2,"reason":"non-string key","key":42,"value":"answer"},{"position":4,"reason":"dangling key","key":"dangling"}]`,
+		`"source":"dynamic"`,
+	} {
+		if !strings.Contains(lines[0], expected) {
+			t.Errorf("expected %s in %s", expected, lines[0])
+		}
+	}
+
+	if !strings.Contains(lines[1], `"shard":3`) || !strings.Contains(lines[1], `"source":"dynamic"`) {
+		t.Errorf("unexpected output %s", lines[1])
+	}
+}
+
+func TestDynamicKeyAndValuesInStructuredCalls(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(WithWriter(buffer), WithDynamicKeyAndValues(func(ctx context.Context) []interface{} {
+		return []interface{}{"tid", "665544332211"}
+	}))
+
+	logger.Info(testContext, "Info test")
+	logger.Infof(testContext, "Infof test")
+
+	if count := strings.Count(buffer.String(), `"tid":"665544332211"`); count != 2 {
+		t.Errorf("expected dynamic key and values in both entries of %s", buffer.String())
+	}
+}
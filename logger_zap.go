@@ -3,224 +3,744 @@ package log
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// zapTraceLevel is below zapcore.DebugLevel, zap has no trace level of its own
+const zapTraceLevel = zapcore.DebugLevel - 1
+
 type ZapLogger struct {
 	*zap.Logger
+	closer              io.Closer
 	dynamicFields       func(context.Context) []Field
 	dynamicKeyAndValues func(context.Context) []interface{}
+	traceExtractor      func(context.Context) (TraceContext, bool)
+	development         bool
+	// helper is the logger used by the package level functions, which call
+	// it one frame deeper than direct method calls
+	helper *ZapLogger
 }
 
 func NewZapLogger(parameter *Parameter) *ZapLogger {
-	var encoder zapcore.Encoder
-	if parameter.Encoder == Console {
-		encoder = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
-	} else {
-		encoder = zapcore.NewJSONEncoder(zap.NewDevelopmentEncoderConfig())
+	outputs := parameter.Outputs
+	if len(outputs) == 0 {
+		outputs = []Output{{}}
+	}
+
+	// static fields are added to every core, so encoders can treat them as
+	// the resource of the entries
+	var static []zap.Field
+	if len(parameter.StaticFields) > 0 {
+		static = ZapLogger{}.parseFields(parameter.StaticFields)
+	}
+
+	cores := make([]zapcore.Core, 0, len(outputs))
+	writers := newZapWriters()
+	for _, output := range outputs {
+		cores = append(cores, newZapCore(parameter, output, static, writers))
 	}
 
-	writer := zapcore.AddSync(parameter.Writer)
+	core := zapcore.NewTee(cores...)
 
-	level := newZapLogLevel(parameter.LogLevel)
-	core := zapcore.NewCore(encoder, writer, zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= level
-	}))
+	options := []zap.Option{zap.AddCaller(), zap.AddCallerSkip(1), zap.WithFatalHook(syncExitHook{core})}
+	if parameter.Development {
+		options = append(options, zap.Development())
+	}
 
 	logger := &ZapLogger{
-		Logger:              zap.New(core, zap.AddCaller(), zap.AddCallerSkip(2)),
+		Logger:              zap.New(core, options...),
 		dynamicFields:       parameter.DynamicFields,
 		dynamicKeyAndValues: parameter.DynamicKeyAndValues,
+		traceExtractor:      parameter.TraceExtractor,
+		development:         parameter.Development,
 	}
 
-	if len(parameter.StaticFields) > 0 {
-		logger.Logger = logger.Logger.With(logger.parseFields(parameter.StaticFields)...)
+	if logger.traceExtractor == nil {
+		logger.traceExtractor = TraceFromContext
+	}
+
+	if len(writers.closers) > 0 {
+		logger.closer = writers.closers
 	}
 
-	return logger
+	return logger.withHelper()
 }
 
-func (l ZapLogger) Debug(ctx context.Context, message string, fields ...Field) {
-	if l.dynamicFields != nil {
-		fields = append(fields, l.dynamicFields(ctx)...)
+// withHelper sets the helper of l, a copy reporting the caller of the package
+// level function instead of the function itself
+func (l *ZapLogger) withHelper() *ZapLogger {
+	helper := *l
+	helper.Logger = l.Logger.WithOptions(zap.AddCallerSkip(1))
+	helper.helper = nil
+	l.helper = &helper
+
+	return l
+}
+
+// newZapCore creates the core of an output with the static fields, zero
+// fields of the output are taken from the parameter. The writer of the output
+// is registered with writers to be closed by the logger.
+func newZapCore(parameter *Parameter, output Output, static []zap.Field, writers *zapWriters) zapcore.Core {
+	if output.Writer == nil {
+		output.Writer = parameter.Writer
+	}
+
+	level := output.AtomicLevel
+	if level.value == nil && output.LogLevel != "" {
+		level = NewAtomicLevel(output.LogLevel)
+	}
+	if level.value == nil {
+		level = parameter.AtomicLevel
+	}
+	if level.value == nil {
+		level = NewAtomicLevel(parameter.LogLevel)
+	}
+
+	// outputs with their own encoder frame every entry in a single write, so
+	// they are never buffered
+	encoder, writer := output.encoder, output.Writer
+	if encoder == nil {
+		if output.Encoder == "" {
+			output.Encoder = parameter.Encoder
+		}
+		if output.Async == nil {
+			output.Async = parameter.Async
+		}
+		if output.EncoderConfig == nil {
+			output.EncoderConfig = parameter.EncoderConfig
+		}
+
+		encoder = newZapEncoder(output.Encoder, output.EncoderConfig)
+		if output.Async != nil {
+			writer = writers.asyncWriter(output.Writer, func() *AsyncWriter {
+				config := *output.Async
+				config.OnDropped = droppedReporter(encoder, output.Writer, "async writer dropped log entries", config.OnDropped)
+				return NewAsyncWriter(output.Writer, config)
+			})
+		}
+	}
+	writers.addCloser(output.Writer, writer)
+
+	var enabler zapcore.LevelEnabler = zapLevelEnabler{level}
+	if output.routeFrom != "" || output.routeBelow != "" {
+		enabler = newZapLevelRange(enabler, output.routeFrom, output.routeBelow)
 	}
 
-	l.Logger.Debug(message, l.parseFields(fields)...)
+	if resource, ok := encoder.(resourceEncoder); ok {
+		return zapcore.NewCore(resource.withResource(static), zapcore.AddSync(writer), enabler)
+	}
+
+	core := zapcore.NewCore(encoder, zapcore.AddSync(writer), enabler)
+	if len(static) > 0 {
+		core = core.With(static)
+	}
+
+	return core
+}
+
+// zapWriters keeps the writers of the outputs of a logger by the writer they
+// write to, so outputs sharing a writer share its AsyncWriter and the writer
+// is closed once. Writers that can't be compared are never shared.
+type zapWriters struct {
+	async   map[io.Writer]*AsyncWriter
+	indexes map[io.Writer]int
+	closers multiCloser
+}
+
+func newZapWriters() *zapWriters {
+	return &zapWriters{async: map[io.Writer]*AsyncWriter{}, indexes: map[io.Writer]int{}}
+}
+
+// asyncWriter returns the AsyncWriter writing to w, it is created by create
+// for the first asynchronous output of w
+func (z *zapWriters) asyncWriter(w io.Writer, create func() *AsyncWriter) *AsyncWriter {
+	if !comparableWriter(w) {
+		return create()
+	}
+
+	async, ok := z.async[w]
+	if !ok {
+		async = create()
+		z.async[w] = async
+	}
+
+	return async
+}
+
+// addCloser adds the closer of writer, the writer of an output writing to w.
+// An AsyncWriter replaces a plain closer of w, since it closes w after writing
+// its queue.
+func (z *zapWriters) addCloser(w io.Writer, writer io.Writer) {
+	closer := newCloser(writer)
+	if closer == nil {
+		return
+	}
+
+	if !comparableWriter(w) {
+		z.closers = append(z.closers, closer)
+		return
+	}
+
+	index, ok := z.indexes[w]
+	if !ok {
+		z.indexes[w] = len(z.closers)
+		z.closers = append(z.closers, closer)
+		return
+	}
+
+	if _, async := writer.(*AsyncWriter); async {
+		z.closers[index] = closer
+	}
+}
+
+// comparableWriter reports whether w can be a map key
+func comparableWriter(w io.Writer) bool {
+	return w != nil && reflect.TypeOf(w).Comparable()
+}
+
+// resourceEncoder is an encoder writing the static fields as the resource of
+// entries, apart from their fields
+type resourceEncoder interface {
+	zapcore.Encoder
+	withResource(static []zap.Field) zapcore.Encoder
+}
+
+// newZapEncoder creates the encoder e, a nil config takes the default of e
+func newZapEncoder(e Encoder, config *EncoderConfig) zapcore.Encoder {
+	if config == nil {
+		var preset EncoderConfig
+		switch e {
+		case Logfmt:
+			preset = logfmtEncoderConfig()
+		case ECS:
+			preset = ECSEncoderConfig()
+		case GCP:
+			preset = GCPEncoderConfig()
+		case OTel:
+			preset = ProductionEncoderConfig()
+		default:
+			preset = DevelopmentEncoderConfig()
+		}
+		config = &preset
+	}
+
+	encoderConfig := config.zapEncoderConfig(e == ColorConsole)
+
+	var encoder zapcore.Encoder
+	switch e {
+	case Console, ColorConsole:
+		encoder = consoleEncoder{zapcore.NewConsoleEncoder(encoderConfig)}
+	case Logfmt:
+		encoder = newLogfmtEncoder(encoderConfig)
+	case ECS:
+		return newECSEncoder(*config)
+	case GCP:
+		return newGCPEncoder(*config)
+	case OTel:
+		return newOTelEncoder(*config, nil)
+	default:
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	}
+
+	if config.Namespace != "" {
+		encoder.OpenNamespace(config.Namespace)
+	}
+
+	return encoder
+}
+
+func (l ZapLogger) Trace(ctx context.Context, message string, fields ...Field) {
+	if ce := l.Logger.Check(zapTraceLevel, message); ce != nil {
+		l.write(ctx, ce, fields)
+	}
+}
+
+func (l ZapLogger) Tracew(ctx context.Context, message string, keyAndValues ...interface{}) {
+	if ce := l.Logger.Check(zapTraceLevel, message); ce != nil {
+		l.writew(ctx, ce, keyAndValues)
+	}
+}
+
+func (l ZapLogger) Debug(ctx context.Context, message string, fields ...Field) {
+	if ce := l.Logger.Check(zapcore.DebugLevel, message); ce != nil {
+		l.write(ctx, ce, fields)
+	}
 }
 
 func (l ZapLogger) Debugw(ctx context.Context, message string, keyAndValues ...interface{}) {
-	l.Logger.Sugar().Debugw(message, append(l.dynamicKeyAndValues(ctx), keyAndValues...)...)
+	if ce := l.Logger.Check(zapcore.DebugLevel, message); ce != nil {
+		l.writew(ctx, ce, keyAndValues)
+	}
 }
 
 func (l ZapLogger) Info(ctx context.Context, message string, fields ...Field) {
-	if l.dynamicFields != nil {
-		fields = append(fields, l.dynamicFields(ctx)...)
+	if ce := l.Logger.Check(zapcore.InfoLevel, message); ce != nil {
+		l.write(ctx, ce, fields)
 	}
-
-	l.Logger.Info(message, l.parseFields(fields)...)
 }
 
 func (l ZapLogger) Infow(ctx context.Context, message string, keyAndValues ...interface{}) {
-	l.Logger.Sugar().Infow(message, append(l.dynamicKeyAndValues(ctx), keyAndValues...)...)
+	if ce := l.Logger.Check(zapcore.InfoLevel, message); ce != nil {
+		l.writew(ctx, ce, keyAndValues)
+	}
 }
 
 func (l ZapLogger) Warn(ctx context.Context, message string, fields ...Field) {
-	if l.dynamicFields != nil {
-		fields = append(fields, l.dynamicFields(ctx)...)
+	if ce := l.Logger.Check(zapcore.WarnLevel, message); ce != nil {
+		l.write(ctx, ce, fields)
 	}
-
-	l.Logger.Warn(message, l.parseFields(fields)...)
 }
 
 func (l ZapLogger) Warnw(ctx context.Context, message string, keyAndValues ...interface{}) {
-	l.Logger.Sugar().Warnw(message, append(l.dynamicKeyAndValues(ctx), keyAndValues...)...)
+	if ce := l.Logger.Check(zapcore.WarnLevel, message); ce != nil {
+		l.writew(ctx, ce, keyAndValues)
+	}
 }
 
 func (l ZapLogger) Error(ctx context.Context, message string, fields ...Field) {
-	if l.dynamicFields != nil {
-		fields = append(fields, l.dynamicFields(ctx)...)
+	if ce := l.Logger.Check(zapcore.ErrorLevel, message); ce != nil {
+		l.write(ctx, ce, fields)
 	}
-
-	l.Logger.Error(message, l.parseFields(fields)...)
 }
 
 func (l ZapLogger) Errorw(ctx context.Context, message string, keyAndValues ...interface{}) {
-	l.Logger.Sugar().Errorw(message, append(l.dynamicKeyAndValues(ctx), keyAndValues...)...)
+	if ce := l.Logger.Check(zapcore.ErrorLevel, message); ce != nil {
+		l.writew(ctx, ce, keyAndValues)
+	}
 }
 
-func (l ZapLogger) Panic(ctx context.Context, message string, fields ...Field) {
-	if l.dynamicFields != nil {
-		fields = append(fields, l.dynamicFields(ctx)...)
+func (l ZapLogger) DPanic(ctx context.Context, message string, fields ...Field) {
+	if ce := l.Logger.Check(zapcore.DPanicLevel, message); ce != nil {
+		l.write(ctx, ce, fields)
+	}
+}
+
+func (l ZapLogger) DPanicw(ctx context.Context, message string, keyAndValues ...interface{}) {
+	if ce := l.Logger.Check(zapcore.DPanicLevel, message); ce != nil {
+		l.writew(ctx, ce, keyAndValues)
 	}
+}
 
-	l.Logger.Panic(message, l.parseFields(fields)...)
+func (l ZapLogger) Panic(ctx context.Context, message string, fields ...Field) {
+	if ce := l.Logger.Check(zapcore.PanicLevel, message); ce != nil {
+		l.write(ctx, ce, fields)
+	}
 }
 
 func (l ZapLogger) Panicw(ctx context.Context, message string, keyAndValues ...interface{}) {
-	l.Logger.Sugar().Panicw(message, append(l.dynamicKeyAndValues(ctx), keyAndValues...)...)
+	if ce := l.Logger.Check(zapcore.PanicLevel, message); ce != nil {
+		l.writew(ctx, ce, keyAndValues)
+	}
 }
 
 func (l ZapLogger) Fatal(ctx context.Context, message string, fields ...Field) {
+	if ce := l.Logger.Check(zapcore.FatalLevel, message); ce != nil {
+		l.write(ctx, ce, fields)
+	}
+}
+
+func (l ZapLogger) Fatalw(ctx context.Context, message string, keyAndValues ...interface{}) {
+	if ce := l.Logger.Check(zapcore.FatalLevel, message); ce != nil {
+		l.writew(ctx, ce, keyAndValues)
+	}
+}
+
+func (l ZapLogger) Tracef(ctx context.Context, format string, args ...interface{}) {
+	if ce := l.Logger.Check(zapTraceLevel, format); ce != nil {
+		l.writef(ctx, ce, format, args)
+	}
+}
+
+func (l ZapLogger) Debugf(ctx context.Context, format string, args ...interface{}) {
+	if ce := l.Logger.Check(zapcore.DebugLevel, format); ce != nil {
+		l.writef(ctx, ce, format, args)
+	}
+}
+
+func (l ZapLogger) Infof(ctx context.Context, format string, args ...interface{}) {
+	if ce := l.Logger.Check(zapcore.InfoLevel, format); ce != nil {
+		l.writef(ctx, ce, format, args)
+	}
+}
+
+func (l ZapLogger) Warnf(ctx context.Context, format string, args ...interface{}) {
+	if ce := l.Logger.Check(zapcore.WarnLevel, format); ce != nil {
+		l.writef(ctx, ce, format, args)
+	}
+}
+
+func (l ZapLogger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	if ce := l.Logger.Check(zapcore.ErrorLevel, format); ce != nil {
+		l.writef(ctx, ce, format, args)
+	}
+}
+
+func (l ZapLogger) DPanicf(ctx context.Context, format string, args ...interface{}) {
+	if ce := l.Logger.Check(zapcore.DPanicLevel, format); ce != nil {
+		l.writef(ctx, ce, format, args)
+	}
+}
+
+func (l ZapLogger) Panicf(ctx context.Context, format string, args ...interface{}) {
+	if ce := l.Logger.Check(zapcore.PanicLevel, format); ce != nil {
+		l.writef(ctx, ce, format, args)
+	}
+}
+
+func (l ZapLogger) Fatalf(ctx context.Context, format string, args ...interface{}) {
+	if ce := l.Logger.Check(zapcore.FatalLevel, format); ce != nil {
+		l.writef(ctx, ce, format, args)
+	}
+}
+
+// write writes an entry that passed the level check with the call site fields,
+// the fields carried by ctx, the dynamic fields and the dynamic key-value
+// pairs. On key collisions call site fields take precedence over context
+// fields, which take precedence over dynamic fields and then dynamic key-value
+// pairs. Fields of With and static fields are already encoded by the cores and
+// take no part in the merge. The trace of ctx is added last. DPanic entries are written at error
+// level outside of development mode.
+func (l ZapLogger) write(ctx context.Context, ce *zapcore.CheckedEntry, fields []Field) {
+	if ce.Level == zapcore.DPanicLevel && !l.development {
+		ce.Level = zapcore.ErrorLevel
+	}
+
+	fields = mergeFields(fields, FieldsFromContext(ctx))
 	if l.dynamicFields != nil {
-		fields = append(fields, l.dynamicFields(ctx)...)
+		fields = mergeFields(fields, l.dynamicFields(ctx))
+	}
+	if l.dynamicKeyAndValues != nil {
+		fields = mergeFields(fields, sweetenFields(l.dynamicKeyAndValues(ctx)))
 	}
 
-	l.Logger.Fatal(message, l.parseFields(fields)...)
+	// the zap fields are pooled, so converting fields doesn't allocate
+	zfields := getZapFields()
+	*zfields = l.appendZapFields(*zfields, fields)
+	if l.traceExtractor != nil {
+		if trace, ok := l.traceExtractor(ctx); ok {
+			*zfields = append(*zfields, zap.Inline(zapTrace(trace)))
+		}
+	}
+
+	ce.Write(*zfields...)
+	putZapFields(zfields)
 }
 
-func (l ZapLogger) Fatalw(ctx context.Context, message string, keyAndValues ...interface{}) {
-	l.Logger.Sugar().Fatalw(message, append(l.dynamicKeyAndValues(ctx), keyAndValues...)...)
+// writew writes an entry that passed the level check with loosely typed
+// key-value pairs, which are merged like the fields in write
+func (l ZapLogger) writew(ctx context.Context, ce *zapcore.CheckedEntry, keyAndValues []interface{}) {
+	l.write(ctx, ce, sweetenFields(keyAndValues))
 }
 
-func (l ZapLogger) parseFields(fields []Field) []zap.Field {
-	zfields := make([]zap.Field, len(fields))
-	for index, field := range fields {
-		switch field.Type {
-		case BinaryType:
-			zfields[index] = zap.Binary(field.Key, field.Value.([]byte))
-		case BoolType:
-			zfields[index] = zap.Bool(field.Key, field.Value.(bool))
-		case ByteStringType:
-			zfields[index] = zap.ByteString(field.Key, field.Value.([]byte))
-		case Complex128Type:
-			zfields[index] = zap.Complex128(field.Key, field.Value.(complex128))
-		case Complex64Type:
-			zfields[index] = zap.Complex64(field.Key, field.Value.(complex64))
-		case DurationType:
-			zfields[index] = zap.Duration(field.Key, field.Value.(time.Duration))
-		case Float64Type:
-			zfields[index] = zap.Float64(field.Key, field.Value.(float64))
-		case Float32Type:
-			zfields[index] = zap.Float32(field.Key, field.Value.(float32))
-		case Int64Type:
-			zfields[index] = zap.Int64(field.Key, field.Value.(int64))
-		case Int32Type:
-			zfields[index] = zap.Int32(field.Key, field.Value.(int32))
-		case Int16Type:
-			zfields[index] = zap.Int16(field.Key, field.Value.(int16))
-		case Int8Type:
-			zfields[index] = zap.Int8(field.Key, field.Value.(int8))
-		case IntType:
-			zfields[index] = zap.Int(field.Key, field.Value.(int))
-		case StringType:
-			zfields[index] = zap.String(field.Key, field.Value.(string))
-		case TimeType:
-			zfields[index] = zap.Time(field.Key, field.Value.(time.Time))
-		case Uint64Type:
-			zfields[index] = zap.Uint64(field.Key, field.Value.(uint64))
-		case Uint32Type:
-			zfields[index] = zap.Uint32(field.Key, field.Value.(uint32))
-		case Uint16Type:
-			zfields[index] = zap.Uint16(field.Key, field.Value.(uint16))
-		case Uint8Type:
-			zfields[index] = zap.Uint8(field.Key, field.Value.(uint8))
-		case UintType:
-			zfields[index] = zap.Uint(field.Key, field.Value.(uint))
-		case UintptrType:
-			zfields[index] = zap.Uintptr(field.Key, field.Value.(uintptr))
-		case ReflectType:
-			zfields[index] = zap.Reflect(field.Key, field.Value)
-		case NamespaceType:
-			zfields[index] = zap.Namespace(field.Key)
-		case StringerType:
-			zfields[index] = zap.Stringer(field.Key, field.Value.(fmt.Stringer))
-		case ErrorType:
-			zfields[index] = zap.NamedError(field.Key, field.Value.(error))
-		case SkipType:
-			zfields[index] = zap.Skip()
-		case BoolsType:
-			zfields[index] = zap.Bools(field.Key, field.Value.([]bool))
-		case ByteStringsType:
-			zfields[index] = zap.ByteStrings(field.Key, field.Value.([][]byte))
-		case Complex128sType:
-			zfields[index] = zap.Complex128s(field.Key, field.Value.([]complex128))
-		case Complex64sType:
-			zfields[index] = zap.Complex64s(field.Key, field.Value.([]complex64))
-		case DurationsType:
-			zfields[index] = zap.Durations(field.Key, field.Value.([]time.Duration))
-		case Float64sType:
-			zfields[index] = zap.Float64s(field.Key, field.Value.([]float64))
-		case Float32sType:
-			zfields[index] = zap.Float32s(field.Key, field.Value.([]float32))
-		case Int64sType:
-			zfields[index] = zap.Int64s(field.Key, field.Value.([]int64))
-		case Int32sType:
-			zfields[index] = zap.Int32s(field.Key, field.Value.([]int32))
-		case Int16sType:
-			zfields[index] = zap.Int16s(field.Key, field.Value.([]int16))
-		case Int8sType:
-			zfields[index] = zap.Int8s(field.Key, field.Value.([]int8))
-		case IntsType:
-			zfields[index] = zap.Ints(field.Key, field.Value.([]int))
-		case StringsType:
-			zfields[index] = zap.Strings(field.Key, field.Value.([]string))
-		case TimesType:
-			zfields[index] = zap.Times(field.Key, field.Value.([]time.Time))
-		case Uint64sType:
-			zfields[index] = zap.Uint64s(field.Key, field.Value.([]uint64))
-		case Uint32sType:
-			zfields[index] = zap.Uint32s(field.Key, field.Value.([]uint32))
-		case Uint16sType:
-			zfields[index] = zap.Uint16s(field.Key, field.Value.([]uint16))
-		case Uint8sType:
-			zfields[index] = zap.Uint8s(field.Key, field.Value.([]uint8))
-		case UintsType:
-			zfields[index] = zap.Uints(field.Key, field.Value.([]uint))
-		case UintptrsType:
-			zfields[index] = zap.Uintptrs(field.Key, field.Value.([]uintptr))
-		default:
-			zfields[index] = zap.Any(field.Key, field.Value)
+// writef formats the message of an entry that passed the level check, so
+// disabled entries cost no formatting
+func (l ZapLogger) writef(ctx context.Context, ce *zapcore.CheckedEntry, format string, args []interface{}) {
+	ce.Message = fmt.Sprintf(format, args...)
+	l.write(ctx, ce, nil)
+}
+
+// Log logs a message at the given level. Levels of PANIC and above panic or
+// exit just like their dedicated methods.
+func (l ZapLogger) Log(ctx context.Context, level LogLevel, message string, fields ...Field) {
+	if ce := l.Logger.Check(newZapLogLevel(level), message); ce != nil {
+		l.write(ctx, ce, fields)
+	}
+}
+
+// Logw logs a message with loosely typed key-value pairs at the given level.
+func (l ZapLogger) Logw(ctx context.Context, level LogLevel, message string, keyAndValues ...interface{}) {
+	if ce := l.Logger.Check(newZapLogLevel(level), message); ce != nil {
+		l.writew(ctx, ce, keyAndValues)
+	}
+}
+
+// Enabled reports whether entries at the given level are logged, which
+// allows skipping expensive fields for disabled levels.
+func (l ZapLogger) Enabled(ctx context.Context, level LogLevel) bool {
+	return l.Logger.Core().Enabled(newZapLogLevel(level))
+}
+
+// With creates a child logger and adds structured context to it. Fields added
+// to the child don't affect the parent, and vice versa.
+func (l ZapLogger) With(fields ...Field) Logger {
+	l.Logger = l.Logger.With(l.parseFields(fields)...)
+	return l.withHelper()
+}
+
+// Withw creates a child logger and adds loosely typed key-value pairs to it.
+func (l ZapLogger) Withw(keyAndValues ...interface{}) Logger {
+	return l.With(sweetenFields(keyAndValues)...)
+}
+
+// Named creates a child logger and adds a new name segment to its name.
+// Segments are joined by periods.
+func (l ZapLogger) Named(name string) Logger {
+	l.Logger = l.Logger.Named(name)
+	return l.withHelper()
+}
+
+// Sync flushes any buffered log entries.
+func (l ZapLogger) Sync() error {
+	return l.Logger.Sync()
+}
+
+// Close flushes any buffered log entries and closes the underlying writer.
+// Child loggers share the writer of their parent, so closing any of them
+// closes it for all.
+func (l ZapLogger) Close() error {
+	err := l.Sync()
+	if l.closer != nil {
+		if closeErr := l.closer.Close(); closeErr != nil {
+			err = closeErr
 		}
 	}
 
+	return err
+}
+
+func (l ZapLogger) parseFields(fields []Field) []zap.Field {
+	return l.appendZapFields(make([]zap.Field, 0, len(fields)), fields)
+}
+
+// appendZapFields converts fields to zap fields without allocating for
+// scalar types, the values are moved to the same slots of zapcore.Field
+func (l ZapLogger) appendZapFields(zfields []zap.Field, fields []Field) []zap.Field {
+	for _, field := range fields {
+		zfields = append(zfields, l.newZapField(field))
+	}
+
 	return zfields
 }
 
+func (l ZapLogger) newZapField(field Field) zap.Field {
+	switch field.Type {
+	case ArrayMarshalerType:
+		return zap.Array(field.Key, zapArrayMarshaler{field.Interface.(ArrayMarshaler)})
+	case ObjectMarshalerType:
+		return zap.Object(field.Key, zapObjectMarshaler{field.Interface.(ObjectMarshaler)})
+	case BinaryType:
+		return zap.Field{Key: field.Key, Type: zapcore.BinaryType, Interface: field.Interface}
+	case BoolType:
+		return zap.Field{Key: field.Key, Type: zapcore.BoolType, Integer: field.Integer}
+	case ByteStringType:
+		return zap.Field{Key: field.Key, Type: zapcore.ByteStringType, Interface: field.Interface}
+	case Complex128Type:
+		return zap.Field{Key: field.Key, Type: zapcore.Complex128Type, Interface: field.Interface}
+	case Complex64Type:
+		return zap.Field{Key: field.Key, Type: zapcore.Complex64Type, Interface: field.Interface}
+	case DurationType:
+		return zap.Field{Key: field.Key, Type: zapcore.DurationType, Integer: field.Integer}
+	case Float64Type:
+		return zap.Field{Key: field.Key, Type: zapcore.Float64Type, Integer: field.Integer}
+	case Float32Type:
+		return zap.Field{Key: field.Key, Type: zapcore.Float32Type, Integer: field.Integer}
+	case Int64Type, IntType:
+		return zap.Field{Key: field.Key, Type: zapcore.Int64Type, Integer: field.Integer}
+	case Int32Type:
+		return zap.Field{Key: field.Key, Type: zapcore.Int32Type, Integer: field.Integer}
+	case Int16Type:
+		return zap.Field{Key: field.Key, Type: zapcore.Int16Type, Integer: field.Integer}
+	case Int8Type:
+		return zap.Field{Key: field.Key, Type: zapcore.Int8Type, Integer: field.Integer}
+	case StringType:
+		return zap.Field{Key: field.Key, Type: zapcore.StringType, String: field.String}
+	case TimeType:
+		return zap.Field{Key: field.Key, Type: zapcore.TimeType, Integer: field.Integer, Interface: field.Interface}
+	case TimeFullType:
+		return zap.Field{Key: field.Key, Type: zapcore.TimeFullType, Interface: field.Interface}
+	case Uint64Type, UintType:
+		return zap.Field{Key: field.Key, Type: zapcore.Uint64Type, Integer: field.Integer}
+	case Uint32Type:
+		return zap.Field{Key: field.Key, Type: zapcore.Uint32Type, Integer: field.Integer}
+	case Uint16Type:
+		return zap.Field{Key: field.Key, Type: zapcore.Uint16Type, Integer: field.Integer}
+	case Uint8Type:
+		return zap.Field{Key: field.Key, Type: zapcore.Uint8Type, Integer: field.Integer}
+	case UintptrType:
+		return zap.Field{Key: field.Key, Type: zapcore.UintptrType, Integer: field.Integer}
+	case ReflectType:
+		return zap.Field{Key: field.Key, Type: zapcore.ReflectType, Interface: field.Interface}
+	case NamespaceType:
+		return zap.Namespace(field.Key)
+	case StringerType:
+		return zap.Field{Key: field.Key, Type: zapcore.StringerType, Interface: field.Interface}
+	case ErrorType:
+		return zap.Inline(zapError{key: field.Key, err: field.Interface.(error), logger: l})
+	case SkipType:
+		return zap.Skip()
+	case BoolsType:
+		return zap.Bools(field.Key, field.Interface.([]bool))
+	case ByteStringsType:
+		return zap.ByteStrings(field.Key, field.Interface.([][]byte))
+	case Complex128sType:
+		return zap.Complex128s(field.Key, field.Interface.([]complex128))
+	case Complex64sType:
+		return zap.Complex64s(field.Key, field.Interface.([]complex64))
+	case DurationsType:
+		return zap.Durations(field.Key, field.Interface.([]time.Duration))
+	case Float64sType:
+		return zap.Float64s(field.Key, field.Interface.([]float64))
+	case Float32sType:
+		return zap.Float32s(field.Key, field.Interface.([]float32))
+	case Int64sType:
+		return zap.Int64s(field.Key, field.Interface.([]int64))
+	case Int32sType:
+		return zap.Int32s(field.Key, field.Interface.([]int32))
+	case Int16sType:
+		return zap.Int16s(field.Key, field.Interface.([]int16))
+	case Int8sType:
+		return zap.Int8s(field.Key, field.Interface.([]int8))
+	case IntsType:
+		return zap.Ints(field.Key, field.Interface.([]int))
+	case StringsType:
+		return zap.Strings(field.Key, field.Interface.([]string))
+	case TimesType:
+		return zap.Times(field.Key, field.Interface.([]time.Time))
+	case Uint64sType:
+		return zap.Uint64s(field.Key, field.Interface.([]uint64))
+	case Uint32sType:
+		return zap.Uint32s(field.Key, field.Interface.([]uint32))
+	case Uint16sType:
+		return zap.Uint16s(field.Key, field.Interface.([]uint16))
+	case Uint8sType:
+		return zap.Uint8s(field.Key, field.Interface.([]uint8))
+	case UintsType:
+		return zap.Uints(field.Key, field.Interface.([]uint))
+	case UintptrsType:
+		return zap.Uintptrs(field.Key, field.Interface.([]uintptr))
+	case DictType:
+		return zap.Object(field.Key, zapDict(l.parseFields(field.Interface.([]Field))))
+	default:
+		return zap.Any(field.Key, field.Interface)
+	}
+}
+
+// zapTrace is the inline zapcore.ObjectMarshaler of the trace of an entry,
+// encoders with their own trace keys look for it among the fields
+type zapTrace TraceContext
+
+func (t zapTrace) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("traceId", t.TraceID)
+	if t.SpanID != "" {
+		enc.AddString("spanId", t.SpanID)
+	}
+	enc.AddBool("traceSampled", t.Sampled)
+
+	return nil
+}
+
+// zapFieldsPool reuses the zap fields of entries, so converting fields on
+// every log call doesn't allocate
+var zapFieldsPool = sync.Pool{
+	New: func() interface{} {
+		zfields := make([]zap.Field, 0, 16)
+		return &zfields
+	},
+}
+
+func getZapFields() *[]zap.Field {
+	return zapFieldsPool.Get().(*[]zap.Field)
+}
+
+func putZapFields(zfields *[]zap.Field) {
+	// drop the references to values before pooling
+	for index := range *zfields {
+		(*zfields)[index] = zap.Field{}
+	}
+	*zfields = (*zfields)[:0]
+
+	zapFieldsPool.Put(zfields)
+}
+
+// capitalLevelEncoder is zapcore.CapitalLevelEncoder aware of the trace level
+func capitalLevelEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if level == zapTraceLevel {
+		enc.AppendString(LevelTrace.String())
+		return
+	}
+
+	zapcore.CapitalLevelEncoder(level, enc)
+}
+
+// lowercaseLevelEncoder is zapcore.LowercaseLevelEncoder aware of the trace
+// level
+func lowercaseLevelEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if level == zapTraceLevel {
+		enc.AppendString(strings.ToLower(LevelTrace.String()))
+		return
+	}
+
+	zapcore.LowercaseLevelEncoder(level, enc)
+}
+
+// capitalColorLevelEncoder is zapcore.CapitalColorLevelEncoder aware of the
+// trace level, which is colored like debug
+func capitalColorLevelEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if level == zapTraceLevel {
+		enc.AppendString("\x1b[35m" + LevelTrace.String() + "\x1b[0m")
+		return
+	}
+
+	zapcore.CapitalColorLevelEncoder(level, enc)
+}
+
+// lowercaseColorLevelEncoder is zapcore.LowercaseColorLevelEncoder aware of
+// the trace level
+func lowercaseColorLevelEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if level == zapTraceLevel {
+		enc.AppendString("\x1b[35m" + strings.ToLower(LevelTrace.String()) + "\x1b[0m")
+		return
+	}
+
+	zapcore.LowercaseColorLevelEncoder(level, enc)
+}
+
+// zapLevelEnabler adapts an AtomicLevel to zapcore.LevelEnabler
+type zapLevelEnabler struct {
+	level AtomicLevel
+}
+
+func (e zapLevelEnabler) Enabled(lvl zapcore.Level) bool {
+	return lvl >= newZapLogLevel(e.level.Level())
+}
+
+// zapLevelRange enables the levels of its enabler from from and below below
+type zapLevelRange struct {
+	zapcore.LevelEnabler
+	from  zapcore.Level
+	below zapcore.Level
+}
+
+// newZapLevelRange limits enabler to the levels from from and below below, an
+// empty level leaves that side unbounded
+func newZapLevelRange(enabler zapcore.LevelEnabler, from, below LogLevel) zapLevelRange {
+	r := zapLevelRange{LevelEnabler: enabler, from: zapTraceLevel, below: zapcore.FatalLevel + 1}
+	if from != "" {
+		r.from = newZapLogLevel(from)
+	}
+	if below != "" {
+		r.below = newZapLogLevel(below)
+	}
+
+	return r
+}
+
+func (r zapLevelRange) Enabled(lvl zapcore.Level) bool {
+	return lvl >= r.from && lvl < r.below && r.LevelEnabler.Enabled(lvl)
+}
+
 func newZapLogLevel(level LogLevel) zapcore.Level {
 	switch level {
+	case LevelTrace:
+		return zapTraceLevel
 	case LevelDebug:
 		return zapcore.DebugLevel
 	case LevelInfo:
@@ -229,9 +749,66 @@ func newZapLogLevel(level LogLevel) zapcore.Level {
 		return zapcore.WarnLevel
 	case LevelError:
 		return zapcore.ErrorLevel
+	case LevelDPanic:
+		return zapcore.DPanicLevel
+	case LevelPanic:
+		return zapcore.PanicLevel
 	case LevelFatal:
 		return zapcore.FatalLevel
 	default:
 		return zapcore.InfoLevel
 	}
 }
+
+// newCloser returns the writer as an io.Closer if it owns a resource that
+// should be released on Close. Standard output and error are never closed.
+func newCloser(w io.Writer) io.Closer {
+	if w == os.Stdout || w == os.Stderr {
+		return nil
+	}
+
+	closer, _ := w.(io.Closer)
+	return closer
+}
+
+// multiCloser closes all of its closers and returns the first error
+type multiCloser []io.Closer
+
+func (c multiCloser) Close() error {
+	var err error
+	for _, closer := range c {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// droppedReporter writes a warning with message about entries dropped by an
+// AsyncWriter or OTLPExporter straight to w, it runs on the goroutine of the
+// writer which owns w
+func droppedReporter(encoder zapcore.Encoder, w io.Writer, message string, next func(uint64)) func(uint64) {
+	return func(dropped uint64) {
+		entry := zapcore.Entry{Level: zapcore.WarnLevel, Time: time.Now(), Message: message}
+		if buf, err := encoder.EncodeEntry(entry, []zapcore.Field{zap.Uint64("dropped", dropped)}); err == nil {
+			_, _ = w.Write(buf.Bytes())
+			buf.Free()
+		}
+
+		if next != nil {
+			next(dropped)
+		}
+	}
+}
+
+// syncExitHook flushes the core before terminating the process, so the fatal
+// entry and everything buffered before it reach the writer.
+type syncExitHook struct {
+	core zapcore.Core
+}
+
+func (h syncExitHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {
+	_ = h.core.Sync()
+	os.Exit(1)
+}
@@ -0,0 +1,49 @@
+package log
+
+import "go.uber.org/zap/zapcore"
+
+// zapObjectMarshaler adapts an ObjectMarshaler to zapcore.ObjectMarshaler
+type zapObjectMarshaler struct {
+	marshaler ObjectMarshaler
+}
+
+func (m zapObjectMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return m.marshaler.MarshalLogObject(zapObjectEncoder{enc})
+}
+
+// zapArrayMarshaler adapts an ArrayMarshaler to zapcore.ArrayMarshaler
+type zapArrayMarshaler struct {
+	marshaler ArrayMarshaler
+}
+
+func (m zapArrayMarshaler) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	return m.marshaler.MarshalLogArray(zapArrayEncoder{enc})
+}
+
+// zapObjectEncoder adapts a zapcore.ObjectEncoder to ObjectEncoder, the methods
+// of built-in types share the same signatures
+type zapObjectEncoder struct {
+	zapcore.ObjectEncoder
+}
+
+func (e zapObjectEncoder) AddArray(key string, marshaler ArrayMarshaler) error {
+	return e.ObjectEncoder.AddArray(key, zapArrayMarshaler{marshaler})
+}
+
+func (e zapObjectEncoder) AddObject(key string, marshaler ObjectMarshaler) error {
+	return e.ObjectEncoder.AddObject(key, zapObjectMarshaler{marshaler})
+}
+
+// zapArrayEncoder adapts a zapcore.ArrayEncoder to ArrayEncoder, the methods
+// of built-in types share the same signatures
+type zapArrayEncoder struct {
+	zapcore.ArrayEncoder
+}
+
+func (e zapArrayEncoder) AppendArray(marshaler ArrayMarshaler) error {
+	return e.ArrayEncoder.AppendArray(zapArrayMarshaler{marshaler})
+}
+
+func (e zapArrayEncoder) AppendObject(marshaler ObjectMarshaler) error {
+	return e.ArrayEncoder.AppendObject(zapObjectMarshaler{marshaler})
+}
@@ -3,15 +3,23 @@ package log
 import (
 	"context"
 	"io"
+
+	"go.uber.org/zap/zapcore"
 )
 
 type Parameter struct {
 	Encoder             Encoder
+	EncoderConfig       *EncoderConfig
 	Writer              io.Writer
+	Async               *AsyncWriterConfig
+	Outputs             []Output
 	LogLevel            LogLevel
+	AtomicLevel         AtomicLevel
+	Development         bool
 	StaticFields        []Field
 	DynamicFields       func(context.Context) []Field
 	DynamicKeyAndValues func(context.Context) []interface{}
+	TraceExtractor      func(context.Context) (TraceContext, bool)
 }
 
 type Encoder string
@@ -19,12 +27,51 @@ type Encoder string
 const (
 	JSON    Encoder = "json"
 	Console Encoder = "console"
+	// ColorConsole is Console with colored levels
+	ColorConsole Encoder = "color_console"
+	// Logfmt renders key=value pairs with nested objects and arrays flattened
+	// to dotted keys
+	Logfmt Encoder = "logfmt"
+	// ECS renders Elastic Common Schema documents, it keeps its own keys and
+	// formats and takes only the namespace and caller path from EncoderConfig
+	ECS Encoder = "ecs"
+	// GCP renders Cloud Logging structured JSON with severities, source
+	// locations and traces, it takes only the namespace and project from
+	// EncoderConfig. Cloud Logging links entries to Cloud Trace only when the
+	// project is set by ProjectID or GOOGLE_CLOUD_PROJECT, without it the bare
+	// trace id is written.
+	GCP Encoder = "gcp"
+	// OTel renders the OpenTelemetry log data model with static fields as
+	// the Resource, it takes only the formats of time and duration fields from
+	// EncoderConfig
+	OTel Encoder = "otel"
 )
 
 func (e Encoder) String() string {
 	return string(e)
 }
 
+// Output is a destination of log entries with its own encoder, writer and
+// level. Zero fields are taken from the logger, so an output without a level
+// follows WithLogLevel or WithAtomicLevel. Asynchronous outputs with the same
+// writer share one AsyncWriter, configured by the first of them.
+type Output struct {
+	Encoder       Encoder
+	EncoderConfig *EncoderConfig
+	Writer        io.Writer
+	LogLevel      LogLevel
+	AtomicLevel   AtomicLevel
+	Async         *AsyncWriterConfig
+
+	// encoder replaces Encoder for outputs like syslog that frame entries
+	// themselves
+	encoder zapcore.Encoder
+
+	// routeFrom and routeBelow limit the levels written by WithLevelRouting
+	routeFrom  LogLevel
+	routeBelow LogLevel
+}
+
 // Option logger option
 type Option func(*Parameter)
 
@@ -34,18 +81,69 @@ func WithEncoder(e Encoder) Option {
 	}
 }
 
+// WithEncoderConfig sets the keys and formats of the encoder, without it JSON
+// and Console use DevelopmentEncoderConfig, ECS uses ECSEncoderConfig, GCP uses
+// GCPEncoderConfig and OTel uses ProductionEncoderConfig
+func WithEncoderConfig(config EncoderConfig) Option {
+	return func(c *Parameter) {
+		c.EncoderConfig = &config
+	}
+}
+
 func WithWriter(w io.Writer) Option {
 	return func(c *Parameter) {
 		c.Writer = w
 	}
 }
 
+// WithAsyncWriter writes entries to the writer on a background goroutine,
+// Sync and Close flush the entries still queued
+func WithAsyncWriter(config AsyncWriterConfig) Option {
+	return func(c *Parameter) {
+		c.Async = &config
+	}
+}
+
+// WithOutputs writes every entry to all outputs whose level enables it, it
+// replaces the single output configured by WithEncoder and WithWriter
+func WithOutputs(outputs ...Output) Option {
+	return func(c *Parameter) {
+		c.Outputs = outputs
+	}
+}
+
+// WithLevelRouting writes entries at threshold and above to high and lower
+// entries to low, both with the encoder and level of the logger. It replaces
+// the outputs configured by WithOutputs.
+func WithLevelRouting(threshold LogLevel, high, low io.Writer) Option {
+	return func(c *Parameter) {
+		c.Outputs = []Output{
+			{Writer: low, routeBelow: threshold},
+			{Writer: high, routeFrom: threshold},
+		}
+	}
+}
+
 func WithLogLevel(level LogLevel) Option {
 	return func(c *Parameter) {
 		c.LogLevel = level
 	}
 }
 
+// WithAtomicLevel sets a level that can be changed at runtime, it overrides WithLogLevel
+func WithAtomicLevel(level AtomicLevel) Option {
+	return func(c *Parameter) {
+		c.AtomicLevel = level
+	}
+}
+
+// WithDevelopment puts the logger in development mode, which makes DPanic panic
+func WithDevelopment(development bool) Option {
+	return func(c *Parameter) {
+		c.Development = development
+	}
+}
+
 func WithStaticFields(fields []Field) Option {
 	return func(c *Parameter) {
 		c.StaticFields = fields
@@ -63,3 +161,12 @@ func WithDynamicKeyAndValues(fn func(context.Context) []interface{}) Option {
 		c.DynamicKeyAndValues = fn
 	}
 }
+
+// WithTraceExtractor sets how loggers find the trace of an entry in its
+// context, like the span of a tracing library. The default is
+// TraceFromContext.
+func WithTraceExtractor(fn func(context.Context) (TraceContext, bool)) Option {
+	return func(c *Parameter) {
+		c.TraceExtractor = fn
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package log
+
+// raceEnabled skips allocation tests, the race detector makes sync.Pool drop
+// items at random
+const raceEnabled = true
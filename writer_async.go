@@ -0,0 +1,237 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy decides what an AsyncWriter does with a write when its queue
+// is full
+type OverflowPolicy string
+
+const (
+	// OverflowBlock waits for room in the queue
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropNewest drops the entry being written
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowDropOldest drops the oldest queued entry to make room
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+)
+
+// AsyncWriterConfig configures an AsyncWriter, zero values take defaults
+type AsyncWriterConfig struct {
+	// QueueSize is the number of entries waiting to be written, default 1024
+	QueueSize int
+	// FlushInterval is the longest time entries are buffered, default 1s
+	FlushInterval time.Duration
+	// FlushSize is the number of buffered bytes that triggers a flush,
+	// default 256KiB
+	FlushSize int
+	// Overflow decides what to do when the queue is full, default OverflowBlock
+	Overflow OverflowPolicy
+	// ReportInterval is how often OnDropped is called with the entries dropped
+	// since the last call, default 10s
+	ReportInterval time.Duration
+	// OnDropped is called from the writing goroutine when entries were
+	// dropped, loggers created with WithAsyncWriter log a warning through it
+	OnDropped func(dropped uint64)
+}
+
+// AsyncWriter writes to an underlying writer on a background goroutine, so
+// callers don't wait on slow disks or pipes. Entries are buffered and flushed
+// by size, by interval and on Sync.
+type AsyncWriter struct {
+	dropped uint64
+
+	writer io.Writer
+	config AsyncWriterConfig
+
+	mutex  sync.RWMutex
+	closed bool
+	queue  chan []byte
+	syncCh chan chan error
+	done   chan struct{}
+}
+
+// NewAsyncWriter starts an AsyncWriter writing to w
+func NewAsyncWriter(w io.Writer, config AsyncWriterConfig) *AsyncWriter {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1024
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.FlushSize <= 0 {
+		config.FlushSize = 256 * 1024
+	}
+	if config.Overflow == "" {
+		config.Overflow = OverflowBlock
+	}
+	if config.ReportInterval <= 0 {
+		config.ReportInterval = 10 * time.Second
+	}
+
+	a := &AsyncWriter{
+		writer: w,
+		config: config,
+		queue:  make(chan []byte, config.QueueSize),
+		syncCh: make(chan chan error),
+		done:   make(chan struct{}),
+	}
+	go a.run()
+
+	return a
+}
+
+// Write queues a copy of p, the returned error never reports write failures
+// of the underlying writer
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if a.closed {
+		return 0, os.ErrClosed
+	}
+
+	entry := append([]byte(nil), p...)
+
+	switch a.config.Overflow {
+	case OverflowDropNewest:
+		select {
+		case a.queue <- entry:
+		default:
+			atomic.AddUint64(&a.dropped, 1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case a.queue <- entry:
+				return len(p), nil
+			default:
+			}
+
+			select {
+			case <-a.queue:
+				atomic.AddUint64(&a.dropped, 1)
+			default:
+			}
+		}
+	default:
+		a.queue <- entry
+	}
+
+	return len(p), nil
+}
+
+// Sync writes the queued entries and syncs the underlying writer
+func (a *AsyncWriter) Sync() error {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if a.closed {
+		return nil
+	}
+
+	result := make(chan error, 1)
+	a.syncCh <- result
+
+	return <-result
+}
+
+// Close writes the queued entries, stops the background goroutine and closes
+// the underlying writer unless it is standard output or error
+func (a *AsyncWriter) Close() error {
+	a.mutex.Lock()
+	if a.closed {
+		a.mutex.Unlock()
+		return os.ErrClosed
+	}
+
+	a.closed = true
+	close(a.queue)
+	a.mutex.Unlock()
+
+	<-a.done
+
+	if closer := newCloser(a.writer); closer != nil {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// Dropped returns the number of entries dropped since the writer started
+func (a *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+
+	flushTicker := time.NewTicker(a.config.FlushInterval)
+	defer flushTicker.Stop()
+
+	reportTicker := time.NewTicker(a.config.ReportInterval)
+	defer reportTicker.Stop()
+
+	var buffer bytes.Buffer
+	var reported uint64
+
+	flush := func() error {
+		if buffer.Len() == 0 {
+			return nil
+		}
+
+		_, err := a.writer.Write(buffer.Bytes())
+		buffer.Reset()
+
+		return err
+	}
+
+	report := func() {
+		dropped := a.Dropped()
+		if dropped > reported && a.config.OnDropped != nil {
+			a.config.OnDropped(dropped - reported)
+		}
+		reported = dropped
+	}
+
+	for {
+		select {
+		case entry, ok := <-a.queue:
+			if !ok {
+				// the warning follows the entries dropped before it
+				flush()
+				report()
+				flush()
+				return
+			}
+
+			buffer.Write(entry)
+			if buffer.Len() >= a.config.FlushSize {
+				flush()
+			}
+		case <-flushTicker.C:
+			flush()
+		case <-reportTicker.C:
+			report()
+		case result := <-a.syncCh:
+			// write what was queued before Sync was called
+			for pending := len(a.queue); pending > 0; pending-- {
+				buffer.Write(<-a.queue)
+			}
+
+			err := flush()
+			if syncer, ok := a.writer.(interface{ Sync() error }); ok {
+				if syncErr := syncer.Sync(); err == nil {
+					err = syncErr
+				}
+			}
+			result <- err
+		}
+	}
+}
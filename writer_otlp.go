@@ -0,0 +1,350 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// otlpScopeName is the instrumentation scope of the exported records
+const otlpScopeName = "github.com/nzai/log"
+
+// OTLPConfig configures an OTLP/HTTP log exporter, zero values take defaults
+type OTLPConfig struct {
+	// Endpoint is the URL records are posted to, default
+	// http://localhost:4318/v1/logs
+	Endpoint string
+	// Headers are added to every request, like authorization headers
+	Headers map[string]string
+	// BatchSize is the number of records posted together, default 512
+	BatchSize int
+	// FlushInterval is the longest time records wait for a batch, default 5s
+	FlushInterval time.Duration
+	// MaxQueueSize is the number of records waiting to be posted, newer
+	// records are dropped beyond it, default 8 batches
+	MaxQueueSize int
+	// Client posts the batches, default a client with a 10s timeout
+	Client *http.Client
+	// ReportInterval is how often OnDropped is called with the records
+	// dropped since the last call, default 10s
+	ReportInterval time.Duration
+	// OnDropped is called from the exporting goroutine when records were
+	// dropped, outputs created with NewOTLPOutput log a warning through it
+	OnDropped   func(dropped uint64)
+	LogLevel    LogLevel
+	AtomicLevel AtomicLevel
+}
+
+// NewOTLPOutput returns an output exporting entries to an OpenTelemetry
+// collector as OTLP/HTTP JSON. Static fields become the resource, the other
+// fields the attributes of the records.
+func NewOTLPOutput(config OTLPConfig) (Output, error) {
+	exporter, err := newOTLPExporter(config)
+	if err != nil {
+		return Output{}, err
+	}
+
+	encoder := newOTLPEncoder()
+	encoder.report = &otlpReportResource{}
+	exporter.config.OnDropped = droppedReporter(otlpReportEncoder{encoder}, otlpReportWriter{exporter},
+		"otlp exporter dropped log records", config.OnDropped)
+	go exporter.run()
+
+	return Output{
+		Writer:      exporter,
+		LogLevel:    config.LogLevel,
+		AtomicLevel: config.AtomicLevel,
+		encoder:     encoder,
+	}, nil
+}
+
+// OTLPExporter posts the OTLP/JSON log records written to it in batches on a
+// background goroutine. Batches that fail to post are dropped and counted by
+// Dropped, Sync returns their error.
+type OTLPExporter struct {
+	dropped uint64
+
+	config OTLPConfig
+
+	mutex   sync.Mutex
+	records []otlpQueuedRecord
+	closed  bool
+
+	// exportMutex keeps batches in order
+	exportMutex sync.Mutex
+
+	flushCh chan struct{}
+	stopCh  chan struct{}
+	done    chan struct{}
+}
+
+// NewOTLPExporter starts an exporter posting to the endpoint of config
+func NewOTLPExporter(config OTLPConfig) (*OTLPExporter, error) {
+	e, err := newOTLPExporter(config)
+	if err != nil {
+		return nil, err
+	}
+	go e.run()
+
+	return e, nil
+}
+
+// newOTLPExporter creates an exporter without starting its goroutine
+func newOTLPExporter(config OTLPConfig) (*OTLPExporter, error) {
+	if config.Endpoint == "" {
+		config.Endpoint = "http://localhost:4318/v1/logs"
+	}
+	if _, err := url.ParseRequestURI(config.Endpoint); err != nil {
+		return nil, err
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 512
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+	if config.MaxQueueSize <= 0 {
+		config.MaxQueueSize = 8 * config.BatchSize
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.ReportInterval <= 0 {
+		config.ReportInterval = 10 * time.Second
+	}
+
+	return &OTLPExporter{
+		config:  config,
+		flushCh: make(chan struct{}, 1),
+		stopCh:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Write queues one record encoded by an OTLP output, the JSON of its resource
+// attributes on the first line followed by the JSON of the record. Without a
+// line break p is a record without resource.
+func (e *OTLPExporter) Write(p []byte) (int, error) {
+	return e.write(p, false)
+}
+
+// write queues p, reports about dropped records are queued beyond the queue
+// size and while closing
+func (e *OTLPExporter) write(p []byte, report bool) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed && !report {
+		return 0, os.ErrClosed
+	}
+
+	if !report && len(e.records) >= e.config.MaxQueueSize {
+		atomic.AddUint64(&e.dropped, 1)
+		return len(p), nil
+	}
+
+	record := otlpQueuedRecord{record: p}
+	if resource, rest, found := bytes.Cut(p, []byte{'\n'}); found {
+		record = otlpQueuedRecord{resource: string(resource), record: rest}
+	}
+	record.record = append(json.RawMessage(nil), record.record...)
+
+	e.records = append(e.records, record)
+	if len(e.records) >= e.config.BatchSize {
+		select {
+		case e.flushCh <- struct{}{}:
+		default:
+		}
+	}
+
+	return len(p), nil
+}
+
+// Sync posts the queued records
+func (e *OTLPExporter) Sync() error {
+	return e.flush()
+}
+
+// Close posts the queued records and stops the background goroutine
+func (e *OTLPExporter) Close() error {
+	e.mutex.Lock()
+	if e.closed {
+		e.mutex.Unlock()
+		return os.ErrClosed
+	}
+	e.closed = true
+	e.mutex.Unlock()
+
+	close(e.stopCh)
+	<-e.done
+
+	return e.flush()
+}
+
+// Dropped returns the number of records dropped because the queue was full or
+// their batch failed to post
+func (e *OTLPExporter) Dropped() uint64 {
+	return atomic.LoadUint64(&e.dropped)
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+
+	reportTicker := time.NewTicker(e.config.ReportInterval)
+	defer reportTicker.Stop()
+
+	var reported uint64
+	report := func() {
+		dropped := e.Dropped()
+		if dropped > reported && e.config.OnDropped != nil {
+			e.config.OnDropped(dropped - reported)
+		}
+		reported = dropped
+	}
+
+	for {
+		select {
+		case <-e.stopCh:
+			report()
+			return
+		case <-reportTicker.C:
+			report()
+			continue
+		case <-ticker.C:
+		case <-e.flushCh:
+		}
+
+		_ = e.flush()
+	}
+}
+
+// flush posts the queued records in batches and returns the first error, the
+// records of failed batches are dropped
+func (e *OTLPExporter) flush() error {
+	e.exportMutex.Lock()
+	defer e.exportMutex.Unlock()
+
+	e.mutex.Lock()
+	records := e.records
+	e.records = nil
+	e.mutex.Unlock()
+
+	var err error
+	for len(records) > 0 {
+		size := len(records)
+		if size > e.config.BatchSize {
+			size = e.config.BatchSize
+		}
+
+		if postErr := e.post(records[:size]); postErr != nil {
+			atomic.AddUint64(&e.dropped, uint64(size))
+			if err == nil {
+				err = postErr
+			}
+		}
+		records = records[size:]
+	}
+
+	return err
+}
+
+// post posts the records grouped by resource, in the order the resources
+// first appear
+func (e *OTLPExporter) post(records []otlpQueuedRecord) error {
+	var request otlpLogsRequest
+	indexes := make(map[string]int)
+	for _, record := range records {
+		index, ok := indexes[record.resource]
+		if !ok {
+			index = len(request.ResourceLogs)
+			indexes[record.resource] = index
+			request.ResourceLogs = append(request.ResourceLogs, otlpResourceLogs{
+				Resource:  otlpResource{Attributes: json.RawMessage(record.resource)},
+				ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: otlpScopeName}}},
+			})
+		}
+
+		scopeLogs := &request.ResourceLogs[index].ScopeLogs[0]
+		scopeLogs.LogRecords = append(scopeLogs.LogRecords, record.record)
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// drain the body so the connection is reused
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("export %d log records failed with status %s", len(records), resp.Status)
+	}
+
+	return nil
+}
+
+// otlpQueuedRecord is a record waiting to be posted with the JSON of the
+// attributes of its resource
+type otlpQueuedRecord struct {
+	resource string
+	record   json.RawMessage
+}
+
+// otlpReportWriter queues the warnings about dropped records even when the
+// queue is full
+type otlpReportWriter struct {
+	exporter *OTLPExporter
+}
+
+func (w otlpReportWriter) Write(p []byte) (int, error) {
+	return w.exporter.write(p, true)
+}
+
+// otlpLogsRequest is the ExportLogsServiceRequest of the OTLP/JSON encoding
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes json.RawMessage `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope         `json:"scope"`
+	LogRecords []json.RawMessage `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
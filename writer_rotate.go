@@ -0,0 +1,456 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateInterval is the period after which a RotatingFile rotates regardless
+// of its size
+type RotateInterval string
+
+const (
+	RotateNever  RotateInterval = ""
+	RotateHourly RotateInterval = "hourly"
+	RotateDaily  RotateInterval = "daily"
+)
+
+const (
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	compressSuffix   = ".gz"
+)
+
+// RotatingFileConfig configures a RotatingFile
+type RotatingFileConfig struct {
+	// Filename is the file to write logs to, backups are kept in the same
+	// directory as name-<timestamp>.ext
+	Filename string
+	// MaxSize is the size in bytes after which the file is rotated, zero
+	// disables rotating by size
+	MaxSize int64
+	// Interval rotates the file at the start of every hour or day
+	Interval RotateInterval
+	// MaxAge removes backups older than it, zero keeps backups of any age
+	MaxAge time.Duration
+	// MaxBackups removes the oldest backups beyond it, zero keeps them all
+	MaxBackups int
+	// Compress gzips backups in the background
+	Compress bool
+	// LocalTime uses the local time zone for backup names and rotation
+	// boundaries instead of UTC
+	LocalTime bool
+}
+
+// RotatingFile is an io.WriteCloser writing to a file that is rotated by size
+// and by time. It can be passed to WithWriter.
+type RotatingFile struct {
+	config RotatingFileConfig
+	now    func() time.Time
+
+	mutex sync.Mutex
+	// file is nil after Close, or after a failed rotation until the next
+	// write reopens it
+	file         *os.File
+	closed       bool
+	size         int64
+	nextRotation time.Time
+
+	millOnce sync.Once
+	millCh   chan struct{}
+	millDone chan struct{}
+}
+
+// NewRotatingFile opens or creates the file in config
+func NewRotatingFile(config RotatingFileConfig) (*RotatingFile, error) {
+	if config.Filename == "" {
+		return nil, errors.New("rotating file requires a filename")
+	}
+
+	switch config.Interval {
+	case RotateNever, RotateHourly, RotateDaily:
+	default:
+		return nil, fmt.Errorf("unknown rotate interval %q", config.Interval)
+	}
+
+	f := &RotatingFile{config: config, now: time.Now}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.openExisting(); err != nil {
+		return nil, err
+	}
+
+	// the file is from an earlier interval, like after a restart
+	if !f.nextRotation.IsZero() && !f.currentTime().Before(f.nextRotation) {
+		if err := f.rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.reopen(); err != nil {
+		return 0, err
+	}
+
+	now := f.currentTime()
+	if !f.nextRotation.IsZero() && !now.Before(f.nextRotation) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	} else if f.config.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.config.MaxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// Rotate moves the current file to a backup and starts a new one, it can be
+// called on a signal like SIGHUP
+func (f *RotatingFile) Rotate() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.reopen(); err != nil {
+		return err
+	}
+
+	return f.rotate()
+}
+
+// Sync commits the content of the current file to stable storage
+func (f *RotatingFile) Sync() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.reopen(); err != nil {
+		return err
+	}
+
+	return f.file.Sync()
+}
+
+// Close closes the current file and waits for pending compressions and
+// removals of backups
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	if f.closed {
+		f.mutex.Unlock()
+		return os.ErrClosed
+	}
+
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+	}
+	f.file = nil
+	f.closed = true
+
+	millCh, millDone := f.millCh, f.millDone
+	f.mutex.Unlock()
+
+	if millCh != nil {
+		close(millCh)
+		<-millDone
+	}
+
+	return err
+}
+
+func (f *RotatingFile) currentTime() time.Time {
+	if f.config.LocalTime {
+		return f.now()
+	}
+
+	return f.now().UTC()
+}
+
+// reopen opens the file again after a failed rotation
+func (f *RotatingFile) reopen() error {
+	if f.closed {
+		return os.ErrClosed
+	}
+	if f.file != nil {
+		return nil
+	}
+
+	return f.openExisting()
+}
+
+// openExisting appends to the file if it exists, otherwise creates it. The
+// next rotation follows the last modification of a file with content, so a
+// file from an earlier interval is due.
+func (f *RotatingFile) openExisting() error {
+	if err := os.MkdirAll(filepath.Dir(f.config.Filename), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.config.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	modified := f.currentTime()
+	if info.Size() > 0 {
+		modified = info.ModTime().In(modified.Location())
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.nextRotation = f.rotationAfter(modified)
+
+	return nil
+}
+
+// rotate moves the file to a backup and creates a new one, on failure the
+// file is left nil for the next write to reopen
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return err
+	}
+
+	now := f.currentTime()
+	if err := os.Rename(f.config.Filename, f.backupName(now)); err != nil && !os.IsNotExist(err) {
+		// keep writing to the current file
+		if openErr := f.openExisting(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+
+	file, err := os.OpenFile(f.config.Filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	f.file = file
+	f.size = 0
+	f.nextRotation = f.rotationAfter(now)
+	f.mill()
+
+	return nil
+}
+
+// rotationAfter returns the next hour or day boundary after t
+func (f *RotatingFile) rotationAfter(t time.Time) time.Time {
+	switch f.config.Interval {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+	case RotateDaily:
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Time{}
+	}
+}
+
+// backupName returns an unused backup name for a rotation at t, rotations
+// within the same millisecond are moved to the following ones
+func (f *RotatingFile) backupName(t time.Time) string {
+	dir, prefix, ext := f.nameParts()
+	for {
+		name := filepath.Join(dir, prefix+t.Format(backupTimeFormat)+ext)
+		if !exists(name) && !exists(name+compressSuffix) {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func (f *RotatingFile) nameParts() (dir, prefix, ext string) {
+	base := filepath.Base(f.config.Filename)
+	ext = filepath.Ext(base)
+
+	return filepath.Dir(f.config.Filename), strings.TrimSuffix(base, ext) + "-", ext
+}
+
+// mill starts the background goroutine compressing and removing backups on
+// first use, and signals it after every rotation
+func (f *RotatingFile) mill() {
+	if !f.config.Compress && f.config.MaxAge <= 0 && f.config.MaxBackups <= 0 {
+		return
+	}
+
+	f.millOnce.Do(func() {
+		f.millCh = make(chan struct{}, 1)
+		f.millDone = make(chan struct{})
+
+		go func() {
+			defer close(f.millDone)
+			for range f.millCh {
+				_ = f.millRun()
+			}
+		}()
+	})
+
+	select {
+	case f.millCh <- struct{}{}:
+	default:
+	}
+}
+
+type logBackup struct {
+	name      string
+	timestamp time.Time
+}
+
+// millRun compresses backups and removes the ones beyond MaxBackups and
+// MaxAge
+func (f *RotatingFile) millRun() error {
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	var removes []logBackup
+	if f.config.MaxBackups > 0 && len(backups) > f.config.MaxBackups {
+		removes = append(removes, backups[f.config.MaxBackups:]...)
+		backups = backups[:f.config.MaxBackups]
+	}
+
+	if f.config.MaxAge > 0 {
+		cutoff := f.currentTime().Add(-f.config.MaxAge)
+
+		var remains []logBackup
+		for _, backup := range backups {
+			if backup.timestamp.Before(cutoff) {
+				removes = append(removes, backup)
+			} else {
+				remains = append(remains, backup)
+			}
+		}
+		backups = remains
+	}
+
+	var errs []string
+	for _, backup := range removes {
+		if err := os.Remove(backup.name); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if f.config.Compress {
+		for _, backup := range backups {
+			if strings.HasSuffix(backup.name, compressSuffix) {
+				continue
+			}
+
+			if err := compressFile(backup.name); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// backups returns the backups of the file, newest first
+func (f *RotatingFile) backups() ([]logBackup, error) {
+	dir, prefix, ext := f.nameParts()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	location := time.UTC
+	if f.config.LocalTime {
+		location = time.Local
+	}
+
+	var backups []logBackup
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		timestamp := strings.TrimSuffix(name, compressSuffix)
+		if !strings.HasPrefix(timestamp, prefix) || !strings.HasSuffix(timestamp, ext) {
+			continue
+		}
+		timestamp = strings.TrimSuffix(strings.TrimPrefix(timestamp, prefix), ext)
+
+		t, err := time.ParseInLocation(backupTimeFormat, timestamp, location)
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, logBackup{name: filepath.Join(dir, name), timestamp: t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].timestamp.After(backups[j].timestamp)
+	})
+
+	return backups, nil
+}
+
+// compressFile gzips name into name.gz and removes name
+func compressFile(name string) error {
+	source, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	temp := name + compressSuffix + ".tmp"
+	target, err := os.OpenFile(temp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(target)
+	if _, err = io.Copy(writer, source); err == nil {
+		err = writer.Close()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+
+	if err := os.Rename(temp, name+compressSuffix); err != nil {
+		return err
+	}
+
+	source.Close()
+	return os.Remove(name)
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
@@ -0,0 +1,255 @@
+package log
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SyslogFormat is the message format of a syslog output
+type SyslogFormat string
+
+const (
+	RFC5424 SyslogFormat = "rfc5424"
+	RFC3164 SyslogFormat = "rfc3164"
+)
+
+// Facility is the syslog facility of the messages, the zero value is unset
+// and sends messages as FacilityUser
+type Facility int
+
+const (
+	FacilityKern Facility = iota + 1
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLpr
+	FacilityNews
+	FacilityUucp
+	FacilityCron
+	FacilityAuthpriv
+	FacilityFtp
+)
+
+const (
+	FacilityLocal0 Facility = iota + 17
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// code is the facility number of the syslog protocols, the constants are one
+// above it so that the zero value is unset
+func (f Facility) code() int {
+	return int(f) - 1
+}
+
+// SyslogConfig configures a syslog output
+type SyslogConfig struct {
+	// Network is udp, tcp, unix or unixgram, empty connects to the local
+	// syslog daemon through /dev/log
+	Network string
+	Address string
+	// Format defaults to RFC5424
+	Format SyslogFormat
+	// Facility defaults to FacilityUser
+	Facility Facility
+	// AppName defaults to the name of the executable
+	AppName string
+	// Hostname defaults to the name of the host
+	Hostname string
+	// StructuredDataID is the SD-ID of the element holding the fields in
+	// RFC5424 messages, default fields@32473
+	StructuredDataID string
+	// DialTimeout defaults to 5s
+	DialTimeout time.Duration
+	LogLevel    LogLevel
+	AtomicLevel AtomicLevel
+}
+
+// NewSyslogOutput connects to a syslog server and returns an output writing
+// to it. Fields are sent as RFC5424 structured data or appended to RFC3164
+// messages as key=value pairs.
+func NewSyslogOutput(config SyslogConfig) (Output, error) {
+	if config.Format == "" {
+		config.Format = RFC5424
+	}
+	if config.Format != RFC5424 && config.Format != RFC3164 {
+		return Output{}, errors.New("unknown syslog format " + string(config.Format))
+	}
+	if config.Facility == 0 {
+		config.Facility = FacilityUser
+	}
+	if config.AppName == "" {
+		config.AppName = filepath.Base(os.Args[0])
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.StructuredDataID == "" {
+		config.StructuredDataID = "fields@32473"
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+
+	writer, err := NewSyslogWriter(config.Network, config.Address, config.DialTimeout)
+	if err != nil {
+		return Output{}, err
+	}
+
+	return Output{
+		Writer:      writer,
+		LogLevel:    config.LogLevel,
+		AtomicLevel: config.AtomicLevel,
+		encoder:     newSyslogEncoder(config),
+	}, nil
+}
+
+// SyslogWriter sends every Write as one syslog message, framed for the
+// transport, and reconnects when sending fails
+type SyslogWriter struct {
+	network string
+	address string
+	timeout time.Duration
+
+	mutex       sync.Mutex
+	conn        net.Conn
+	connNetwork string
+}
+
+// NewSyslogWriter connects to a syslog server, an empty network connects to
+// the local syslog daemon
+func NewSyslogWriter(network, address string, timeout time.Duration) (*SyslogWriter, error) {
+	w := &SyslogWriter{network: network, address: address, timeout: timeout}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := w.send(p)
+	if err != nil {
+		// the server may have closed the connection since the last write
+		w.disconnect()
+		err = w.send(p)
+	}
+	if err != nil {
+		w.disconnect()
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close closes the connection to the syslog server
+func (w *SyslogWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+
+	return err
+}
+
+func (w *SyslogWriter) send(p []byte) error {
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.conn.Write(w.frame(p))
+	return err
+}
+
+func (w *SyslogWriter) disconnect() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
+
+// frame applies octet counting framing of RFC 6587 on TCP and terminates
+// messages on unix streams with a newline, escaping the control characters of
+// multi-line messages. Datagrams are sent unframed.
+func (w *SyslogWriter) frame(p []byte) []byte {
+	switch w.connNetwork {
+	case "tcp", "tcp4", "tcp6":
+		framed := strconv.AppendInt(make([]byte, 0, len(p)+8), int64(len(p)), 10)
+		framed = append(framed, ' ')
+		return append(framed, p...)
+	case "unix":
+		return append(appendSyslogLine(make([]byte, 0, len(p)+1), p), '\n')
+	default:
+		return p
+	}
+}
+
+// appendSyslogLine appends p with control characters other than tab escaped
+// like Go string literals, so it doesn't contain the line break ending it
+func appendSyslogLine(dst, p []byte) []byte {
+	for _, c := range p {
+		switch {
+		case c == '\n':
+			dst = append(dst, '\\', 'n')
+		case c == '\r':
+			dst = append(dst, '\\', 'r')
+		case c < ' ' && c != '\t' || c == 0x7f:
+			dst = append(dst, '\\', 'x', syslogHexDigits[c>>4], syslogHexDigits[c&0xf])
+		default:
+			dst = append(dst, c)
+		}
+	}
+
+	return dst
+}
+
+const syslogHexDigits = "0123456789abcdef"
+
+func (w *SyslogWriter) connect() error {
+	if w.network != "" {
+		conn, err := net.DialTimeout(w.network, w.address, w.timeout)
+		if err != nil {
+			return err
+		}
+
+		w.conn, w.connNetwork = conn, w.network
+		return nil
+	}
+
+	// the local daemon listens on a datagram or stream socket depending on
+	// the system
+	var err error
+	for _, address := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+		for _, network := range []string{"unixgram", "unix"} {
+			var conn net.Conn
+			if conn, err = net.DialTimeout(network, address, w.timeout); err == nil {
+				w.conn, w.connNetwork = conn, network
+				return nil
+			}
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,718 @@
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClock is a clock moved by tests and read by background goroutines
+type testClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir failed due to %v", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestRotatingFileBySize(t *testing.T) {
+	dir := t.TempDir()
+	clock := &testClock{now: time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)}
+
+	file, err := NewRotatingFile(RotatingFileConfig{
+		Filename:   filepath.Join(dir, "app.log"),
+		MaxSize:    10,
+		MaxBackups: 2,
+		Compress:   true,
+	})
+	if err != nil {
+		t.Fatalf("create rotating file failed due to %v", err)
+	}
+	file.now = clock.Now
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		clock.Add(time.Second)
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("write failed due to %v", err)
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatalf("close failed due to %v", err)
+	}
+
+	expected := []string{"app-2022-09-01T10-00-03.000.log.gz", "app-2022-09-01T10-00-04.000.log.gz", "app.log"}
+	if names := listDir(t, dir); strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %v but got %v", expected, names)
+	}
+
+	compressed, _ := os.Open(filepath.Join(dir, expected[1]))
+	defer compressed.Close()
+
+	reader, err := gzip.NewReader(compressed)
+	if err != nil {
+		t.Fatalf("open gzip failed due to %v", err)
+	}
+
+	if content, _ := ioutil.ReadAll(reader); string(content) != "third\n" {
+		t.Errorf("unexpected backup content %q", content)
+	}
+
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "app.log")); string(content) != "fourth\n" {
+		t.Errorf("unexpected current content %q", content)
+	}
+}
+
+func TestRotatingFileByTime(t *testing.T) {
+	dir := t.TempDir()
+	clock := &testClock{now: time.Date(2022, 9, 1, 10, 59, 0, 0, time.UTC)}
+
+	file, err := NewRotatingFile(RotatingFileConfig{
+		Filename: filepath.Join(dir, "app.log"),
+		Interval: RotateHourly,
+		MaxAge:   time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("create rotating file failed due to %v", err)
+	}
+	file.now = clock.Now
+	file.nextRotation = file.rotationAfter(clock.Now())
+
+	// a stale backup beyond MaxAge
+	stale := filepath.Join(dir, "app-2022-09-01T08-00-00.000.log")
+	ioutil.WriteFile(stale, []byte("stale\n"), 0644)
+
+	file.Write([]byte("before\n"))
+	clock.Add(time.Minute)
+	file.Write([]byte("after\n"))
+
+	clock.Add(time.Minute)
+	if err := file.Rotate(); err != nil {
+		t.Fatalf("rotate failed due to %v", err)
+	}
+	file.Close()
+
+	expected := []string{"app-2022-09-01T11-00-00.000.log", "app-2022-09-01T11-01-00.000.log", "app.log"}
+	if names := listDir(t, dir); strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %v but got %v", expected, names)
+	}
+
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, expected[0])); string(content) != "before\n" {
+		t.Errorf("unexpected backup content %q", content)
+	}
+
+	if _, err := file.Write([]byte("closed\n")); err == nil {
+		t.Error("expected write after close to fail")
+	}
+}
+
+func TestRotatingFileRecovery(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+
+	// a daily file left from yesterday is rotated on open
+	ioutil.WriteFile(name, []byte("yesterday\n"), 0644)
+	yesterday := time.Now().Add(-24 * time.Hour)
+	os.Chtimes(name, yesterday, yesterday)
+
+	file, err := NewRotatingFile(RotatingFileConfig{Filename: name, Interval: RotateDaily})
+	if err != nil {
+		t.Fatalf("create rotating file failed due to %v", err)
+	}
+
+	if names := listDir(t, dir); len(names) != 2 {
+		t.Errorf("expected the stale file to be rotated but got %v", names)
+	}
+	if content, _ := ioutil.ReadFile(name); len(content) != 0 {
+		t.Errorf("expected an empty file but got %q", content)
+	}
+
+	// a rotation failing to create the new file is recovered by the next write
+	os.RemoveAll(dir)
+	if err := file.Rotate(); err == nil {
+		t.Fatal("expected rotate to fail without the directory")
+	}
+
+	if _, err := file.Write([]byte("recovered\n")); err != nil {
+		t.Fatalf("expected write to reopen the file but got %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("close failed due to %v", err)
+	}
+
+	if content, _ := ioutil.ReadFile(name); string(content) != "recovered\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+// gateWriter records writes, each write waits for the gate to be opened
+type gateWriter struct {
+	mutex   sync.Mutex
+	builder strings.Builder
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func newGateWriter() *gateWriter {
+	return &gateWriter{entered: make(chan struct{}, 1), gate: make(chan struct{})}
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	select {
+	case w.entered <- struct{}{}:
+	default:
+	}
+	<-w.gate
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.builder.Write(p)
+}
+
+func (w *gateWriter) String() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.builder.String()
+}
+
+func TestAsyncWriter(t *testing.T) {
+	writer := newGateWriter()
+	close(writer.gate)
+
+	logger := New(WithWriter(writer), WithAsyncWriter(AsyncWriterConfig{FlushInterval: time.Hour}))
+	defer logger.Close()
+
+	ctx := context.Background()
+	for index := 0; index < 100; index++ {
+		logger.Info(ctx, "async", Int("index", index))
+	}
+
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("sync failed due to %v", err)
+	}
+
+	if lines := strings.Count(writer.String(), "\n"); lines != 100 {
+		t.Fatalf("expected 100 lines after sync but got %d", lines)
+	}
+}
+
+func TestAsyncWriterOverflow(t *testing.T) {
+	tests := []struct {
+		overflow OverflowPolicy
+		written  []string
+		dropped  []string
+	}{
+		{OverflowDropNewest, []string{"m1", "m2", "m3"}, []string{"m4", "m5"}},
+		{OverflowDropOldest, []string{"m1", "m4", "m5"}, []string{"m2", "m3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.overflow), func(t *testing.T) {
+			writer := newGateWriter()
+
+			var reported uint64
+			logger := New(WithWriter(writer), WithAsyncWriter(AsyncWriterConfig{
+				QueueSize: 2,
+				FlushSize: 1,
+				Overflow:  tt.overflow,
+				OnDropped: func(dropped uint64) { reported += dropped },
+			}))
+
+			ctx := context.Background()
+			logger.Info(ctx, "m1")
+			// wait until the first entry is stuck in the underlying writer
+			<-writer.entered
+
+			for _, message := range []string{"m2", "m3", "m4", "m5"} {
+				logger.Info(ctx, message)
+			}
+
+			close(writer.gate)
+			if err := logger.Close(); err != nil {
+				t.Fatalf("close failed due to %v", err)
+			}
+
+			output := writer.String()
+			for _, message := range tt.written {
+				if !strings.Contains(output, `"M":"`+message+`"`) {
+					t.Fatalf("expected %s to be written but got %s", message, output)
+				}
+			}
+			for _, message := range tt.dropped {
+				if strings.Contains(output, `"M":"`+message+`"`) {
+					t.Fatalf("expected %s to be dropped but got %s", message, output)
+				}
+			}
+
+			if !strings.Contains(output, `"M":"async writer dropped log entries","dropped":2`) {
+				t.Fatalf("expected dropped entries to be reported but got %s", output)
+			}
+			if reported != 2 {
+				t.Fatalf("expected 2 dropped entries to be reported but got %d", reported)
+			}
+		})
+	}
+}
+
+func TestAsyncWriterReportOrder(t *testing.T) {
+	writer := newGateWriter()
+	async := NewAsyncWriter(writer, AsyncWriterConfig{
+		QueueSize:     1,
+		FlushInterval: time.Hour,
+		Overflow:      OverflowDropNewest,
+		OnDropped:     func(uint64) { writer.Write([]byte("dropped\n")) },
+	})
+
+	async.Write([]byte("first\n"))
+	synced := make(chan struct{})
+	go func() {
+		async.Sync()
+		close(synced)
+	}()
+	// wait until the first entry is stuck in the underlying writer
+	<-writer.entered
+
+	async.Write([]byte("second\n"))
+	async.Write([]byte("third\n"))
+	close(writer.gate)
+	<-synced
+
+	if err := async.Close(); err != nil {
+		t.Fatalf("close failed due to %v", err)
+	}
+
+	// the report follows the entries buffered when closing
+	if output := writer.String(); output != "first\nsecond\ndropped\n" {
+		t.Errorf("unexpected output %q", output)
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed due to %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		format   SyslogFormat
+		facility Facility
+		expected *regexp.Regexp
+	}{
+		{RFC5424, FacilityLocal4, regexp.MustCompile(`^<164>1 \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{6}\S+ host app \d+ worker ` +
+			`\[fields@32473 caller="\S+/writer_test.go:\d+" note="line\nbreak" quote="a \\"b\\" \\]" request.id="42"\] syslog test$`)},
+		{RFC3164, FacilityLocal4, regexp.MustCompile(`^<164>\w{3} [ \d]\d \d{2}:\d{2}:\d{2} host app\[\d+\]: syslog test ` +
+			`caller=\S+/writer_test.go:\d+ note="line\\nbreak" quote="a \\"b\\" ]" request.id=42$`)},
+		// an unset facility is user
+		{RFC3164, 0, regexp.MustCompile(`^<12>\w{3} `)},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			output, err := NewSyslogOutput(SyslogConfig{
+				Network:  "udp",
+				Address:  conn.LocalAddr().String(),
+				Format:   tt.format,
+				Facility: tt.facility,
+				AppName:  "app",
+				Hostname: "host",
+			})
+			if err != nil {
+				t.Fatalf("create syslog output failed due to %v", err)
+			}
+
+			logger := New(WithOutputs(output)).Named("worker")
+			defer logger.Close()
+
+			logger.Warn(testContext, "syslog test", Dict("request", Int("id", 42)), String("quote", `a "b" ]`),
+				String("note", "line\nbreak"))
+
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			buffer := make([]byte, 4096)
+			n, _, err := conn.ReadFrom(buffer)
+			if err != nil {
+				t.Fatalf("read failed due to %v", err)
+			}
+
+			if message := string(buffer[:n]); !tt.expected.MatchString(message) {
+				t.Fatalf("unexpected message %q", message)
+			}
+		})
+	}
+}
+
+func TestSyslogUnixStream(t *testing.T) {
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "syslog.sock"))
+	if err != nil {
+		t.Fatalf("listen failed due to %v", err)
+	}
+	defer listener.Close()
+
+	output, err := NewSyslogOutput(SyslogConfig{Network: "unix", Address: listener.Addr().String(), AppName: "app", Hostname: "host"})
+	if err != nil {
+		t.Fatalf("create syslog output failed due to %v", err)
+	}
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("accept failed due to %v", err)
+	}
+	defer conn.Close()
+
+	logger := New(WithOutputs(output))
+	defer logger.Close()
+
+	// fields after a namespace stay in it, the parent logger doesn't see them
+	logger.With(Namespace("request"), String("id", "42")).Info(testContext, "first\nsecond", String("note", "a\nb"))
+	logger.Info(testContext, "parent", String("other", "x"))
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	first, _ := reader.ReadString('\n')
+	second, _ := reader.ReadString('\n')
+
+	if !strings.HasPrefix(first, "<14>1 ") ||
+		!strings.HasSuffix(first, ` request.id="42" request.note="a\nb"] first\nsecond`+"\n") {
+		t.Errorf("unexpected first message %q", first)
+	}
+
+	if !strings.HasSuffix(second, ` other="x"] parent`+"\n") || strings.Contains(second, "request") {
+		t.Errorf("unexpected second message %q", second)
+	}
+}
+
+func TestSyslogTCPReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed due to %v", err)
+	}
+	defer listener.Close()
+
+	output, err := NewSyslogOutput(SyslogConfig{Network: "tcp", Address: listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("create syslog output failed due to %v", err)
+	}
+
+	logger := New(WithOutputs(output))
+	defer logger.Close()
+
+	readFrame := func(reader *bufio.Reader) string {
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			t.Fatalf("read frame length failed due to %v", err)
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(length))
+		if err != nil {
+			t.Fatalf("invalid frame length %q", length)
+		}
+
+		frame := make([]byte, size)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			t.Fatalf("read frame failed due to %v", err)
+		}
+
+		return string(frame)
+	}
+
+	first, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("accept failed due to %v", err)
+	}
+
+	logger.Info(testContext, "first message")
+	if frame := readFrame(bufio.NewReader(first)); !strings.HasSuffix(frame, " first message") {
+		t.Fatalf("unexpected frame %q", frame)
+	}
+	first.Close()
+
+	// writes fail once the client notices the closed connection, then it
+	// reconnects
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		logger.Info(testContext, "after reconnect")
+
+		select {
+		case second := <-accepted:
+			defer second.Close()
+			if frame := readFrame(bufio.NewReader(second)); !strings.HasSuffix(frame, " after reconnect") {
+				t.Fatalf("unexpected frame %q", frame)
+			}
+			return
+		case <-deadline:
+			t.Fatal("expected the writer to reconnect")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	requests := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/json" ||
+			r.Header.Get("Authorization") != "token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- body
+	}))
+	defer server.Close()
+
+	output, err := NewOTLPOutput(OTLPConfig{
+		Endpoint:      server.URL + "/v1/logs",
+		Headers:       map[string]string{"Authorization": "token"},
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("create otlp output failed due to %v", err)
+	}
+
+	logger := New(WithOutputs(output), WithStaticFields([]Field{String("service.name", "api")}))
+
+	ctx := ContextWithTrace(testContext, TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true})
+	logger.Info(ctx, "first", Int("attempt", 1), Dict("http", String("method", "GET")))
+	logger.Error(ctx, "second")
+
+	var request struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []otlpKeyValue `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				LogRecords []otlpLogRecord `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+
+	// a full batch is posted without waiting for the interval
+	select {
+	case body := <-requests:
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Fatalf("unmarshal %s failed due to %v", body, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a full batch to be posted")
+	}
+
+	resource := request.ResourceLogs[0].Resource.Attributes
+	if len(resource) != 1 || resource[0].Key != "service.name" || *resource[0].Value.StringValue != "api" {
+		t.Errorf("unexpected resource %+v", resource)
+	}
+
+	records := request.ResourceLogs[0].ScopeLogs[0].LogRecords
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records but got %d", len(records))
+	}
+
+	first := records[0]
+	if first.SeverityNumber != 9 || first.SeverityText != "INFO" || *first.Body.StringValue != "first" ||
+		first.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || first.SpanID != "00f067aa0ba902b7" || first.Flags != 1 {
+		t.Errorf("unexpected record %+v", first)
+	}
+
+	attributes := map[string]otlpAnyValue{}
+	for _, attribute := range first.Attributes {
+		attributes[attribute.Key] = attribute.Value
+	}
+	if value := attributes["attempt"]; value.IntValue == nil || *value.IntValue != "1" {
+		t.Errorf("expected attempt attribute in %+v", first.Attributes)
+	}
+	if value := attributes["http"]; value.KvlistValue == nil || value.KvlistValue.Values[0].Key != "method" {
+		t.Errorf("expected http attribute in %+v", first.Attributes)
+	}
+	if _, ok := attributes["service.name"]; ok {
+		t.Errorf("expected static fields only in the resource")
+	}
+
+	if records[1].SeverityNumber != 17 {
+		t.Errorf("unexpected record %+v", records[1])
+	}
+
+	// Close posts what is left
+	logger.Info(ctx, "third")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("close failed due to %v", err)
+	}
+
+	select {
+	case body := <-requests:
+		if !strings.Contains(string(body), `"stringValue":"third"`) {
+			t.Errorf("expected third record in %s", body)
+		}
+	default:
+		t.Fatal("expected the last record to be posted on close")
+	}
+}
+
+func TestOTLPExporterFailedBatches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var reported uint64
+	output, err := NewOTLPOutput(OTLPConfig{
+		Endpoint:       server.URL,
+		BatchSize:      2,
+		FlushInterval:  time.Hour,
+		ReportInterval: time.Hour,
+		OnDropped:      func(dropped uint64) { reported += dropped },
+	})
+	if err != nil {
+		t.Fatalf("create otlp output failed due to %v", err)
+	}
+	exporter := output.Writer.(*OTLPExporter)
+
+	// a full batch is posted by the background goroutine
+	logger := New(WithOutputs(output))
+	logger.Info(testContext, "first")
+	logger.Info(testContext, "second")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for exporter.Dropped() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if dropped := exporter.Dropped(); dropped != 2 {
+		t.Fatalf("expected the 2 records of the failed batch to be dropped but got %d", dropped)
+	}
+
+	// the report of the drops fails to post too
+	if err := logger.Close(); err == nil {
+		t.Error("expected close to return the error of the last batch")
+	}
+
+	if reported != 2 {
+		t.Errorf("expected 2 dropped records to be reported but got %d", reported)
+	}
+}
+
+func TestOTLPExporterResourcesAndDrops(t *testing.T) {
+	requests := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- body
+	}))
+	defer server.Close()
+
+	var reported uint64
+	output, err := NewOTLPOutput(OTLPConfig{
+		Endpoint:       server.URL,
+		FlushInterval:  time.Hour,
+		ReportInterval: time.Hour,
+		MaxQueueSize:   2,
+		OnDropped:      func(dropped uint64) { reported += dropped },
+	})
+	if err != nil {
+		t.Fatalf("create otlp output failed due to %v", err)
+	}
+
+	// loggers sharing the output keep their own resource
+	api := New(WithOutputs(output), WithStaticFields([]Field{String("service.name", "api")}))
+	worker := New(WithOutputs(output), WithStaticFields([]Field{String("service.name", "worker")}))
+	api.Info(testContext, "api entry")
+	worker.Info(testContext, "worker entry")
+	api.Info(testContext, "dropped entry")
+
+	if err := api.Close(); err != nil {
+		t.Fatalf("close failed due to %v", err)
+	}
+
+	if reported != 1 {
+		t.Errorf("expected 1 dropped record to be reported but got %d", reported)
+	}
+
+	// Close syncs the queued records, then posts the report of the drops
+	messages := map[string][]string{}
+	for len(requests) > 0 {
+		body := <-requests
+
+		var request struct {
+			ResourceLogs []struct {
+				Resource struct {
+					Attributes []otlpKeyValue `json:"attributes"`
+				} `json:"resource"`
+				ScopeLogs []struct {
+					LogRecords []otlpLogRecord `json:"logRecords"`
+				} `json:"scopeLogs"`
+			} `json:"resourceLogs"`
+		}
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Fatalf("unmarshal %s failed due to %v", body, err)
+		}
+
+		for _, resourceLogs := range request.ResourceLogs {
+			service := ""
+			if attributes := resourceLogs.Resource.Attributes; len(attributes) == 1 && attributes[0].Value.StringValue != nil {
+				service = *attributes[0].Value.StringValue
+			}
+
+			for _, record := range resourceLogs.ScopeLogs[0].LogRecords {
+				messages[service] = append(messages[service], *record.Body.StringValue)
+			}
+		}
+	}
+
+	// the report takes the resource of the first logger using the output
+	expected := map[string][]string{
+		"api":    {"api entry", "otlp exporter dropped log records"},
+		"worker": {"worker entry"},
+	}
+	if fmt.Sprint(messages) != fmt.Sprint(expected) {
+		t.Errorf("expected records %v by resource but got %v", expected, messages)
+	}
+}